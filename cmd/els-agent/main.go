@@ -0,0 +1,102 @@
+/*
+Command els-agent is a signing agent which holds the access keys of the
+profiles stored in the ELS profile config file ($HOME/.els/config.json by
+default) and signs requests with them on behalf of other processes, which
+connect to it over a Unix socket, so that their secrets stay in one process.
+
+Usage:
+
+	els-agent [flags]
+
+The socket is created at $ELS_AGENT_SOCK if set, or $HOME/.els/agent.sock,
+and is accessible only to the user. Processes use els.NewAgentSigner to sign
+requests with a key held by the agent. By default the keys of all profiles are
+loaded; use -profile to load only some of them.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/elasticlic/els-api-sdk-go/els"
+	log "github.com/sirupsen/logrus"
+)
+
+func main() {
+	configPath := flag.String("config", els.DefaultProfileConfigPath(), "path of the profile config file")
+	profiles := flag.String("profile", "", "comma-separated names of the profiles whose keys to load (default: all)")
+	socket := flag.String("socket", els.DefaultAgentSocketPath(), "path of the Unix socket to listen on")
+	verbose := flag.Bool("v", false, "log debug output to stderr")
+	flag.Parse()
+
+	log.SetOutput(ioutil.Discard)
+	if *verbose {
+		log.SetOutput(os.Stderr)
+		log.SetLevel(log.DebugLevel)
+	}
+
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c, err := els.LoadProfileConfig(*configPath)
+	if err != nil {
+		fatal(err)
+	}
+
+	names := []string{}
+	if *profiles == "" {
+		for name := range c.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	} else {
+		names = strings.Split(*profiles, ",")
+	}
+
+	agent := els.NewAgent()
+	for _, name := range names {
+		p, err := c.Profile(name)
+		if err != nil {
+			fatal(fmt.Errorf("profile %q: %v", name, err))
+		}
+		if p.AccessKey == nil {
+			continue
+		}
+		if err := agent.Add(p.AccessKey); err != nil {
+			fatal(fmt.Errorf("profile %q: %v", name, err))
+		}
+		fmt.Fprintf(os.Stderr, "els-agent: loaded key %s from profile %q\n", p.AccessKey.ID, name)
+	}
+	if len(agent.Keys()) == 0 {
+		fatal(fmt.Errorf("no access keys in %s", *configPath))
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		agent.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "els-agent: listening on %s\n", *socket)
+
+	err = agent.ListenAndServe(*socket)
+	os.Remove(*socket)
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "els-agent: %v\n", err)
+	os.Exit(1)
+}
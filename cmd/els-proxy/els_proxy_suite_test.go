@@ -0,0 +1,14 @@
+package main
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestElsProxy(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "els-proxy Suite")
+}
@@ -0,0 +1,142 @@
+/*
+Command els-proxy is a local proxy which ELS-signs requests on behalf of tools
+which can't, such as curl, Postman or browser-based admin pages. It accepts
+plain HTTP requests for ELS routes, completes them to the ELS URL, signs them
+with the access key of a profile stored in the ELS profile config file
+($HOME/.els/config.json by default), forwards them and streams back the
+responses.
+
+Usage:
+
+	els-proxy [flags]
+
+For example, with the proxy listening on the default address:
+
+	curl http://127.0.0.1:8080/users/a@b.com
+
+sends a signed GET request for the route /users/a@b.com. Each request is
+logged to stderr. Use -allow to restrict the routes which may be called: a
+pattern matches a route as path.Match does, or if it ends in "/", any route
+with that prefix.
+
+Anything able to connect to the proxy can make calls with the profile's access
+key, so it listens on the loopback interface by default. That includes web
+pages open in a browser on the same machine, which could make the browser send
+requests to the proxy, either directly (e.g. by submitting a form) or under
+their own host name by DNS rebinding. So the proxy refuses requests:
+
+  - whose Host header is not the listen address (or, for a loopback address,
+    localhost with the same port) or a host given with -host;
+  - made by pages from other origins than the proxy itself or those given
+    with -origin, as identified by the Origin header or, for methods other
+    than GET, HEAD and OPTIONS, the Referer header;
+  - for routes with "." or ".." segments or encoded slashes, so that -allow
+    can't be escaped.
+
+Requests from tools such as curl, which send neither header, are accepted.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/elasticlic/els-api-sdk-go/els"
+	"github.com/elasticlic/go-utils/datetime"
+	log "github.com/sirupsen/logrus"
+)
+
+// patterns implements flag.Value, accumulating the values of a flag which may
+// be repeated or given as a comma-separated list.
+type patterns []string
+
+func (p *patterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patterns) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*p = append(*p, s)
+		}
+	}
+	return nil
+}
+
+func main() {
+	var allow, hosts, origins patterns
+	configPath := flag.String("config", els.DefaultProfileConfigPath(), "path of the profile config file")
+	profile := flag.String("profile", els.DefaultProfileName, "name of the profile to use")
+	listen := flag.String("listen", "127.0.0.1:8080", "address to listen on")
+	timeout := flag.Duration("timeout", els.DefaultRequestTimeout, "timeout of each forwarded request")
+	flag.Var(&allow, "allow", "route `pattern` which may be called; may be repeated (default: all routes)")
+	flag.Var(&hosts, "host", "additional `host` (and port) which requests may be addressed to; may be repeated")
+	flag.Var(&origins, "origin", "`origin` of web pages which may make requests through the proxy; may be repeated")
+	verbose := flag.Bool("v", false, "log debug output to stderr")
+	flag.Parse()
+
+	log.SetOutput(ioutil.Discard)
+	if *verbose {
+		log.SetOutput(os.Stderr)
+		log.SetLevel(log.DebugLevel)
+	}
+
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c, err := els.LoadProfileConfig(*configPath)
+	if err != nil {
+		fatal(err)
+	}
+	p, err := c.Profile(*profile)
+	if err != nil {
+		fatal(fmt.Errorf("profile %q: %v", *profile, err))
+	}
+	s, err := p.Signer()
+	if err != nil {
+		fatal(fmt.Errorf("profile %q: %v", *profile, err))
+	}
+
+	px := &proxy{
+		caller:  p.NewAPICaller(nil, datetime.NewNowTimeProvider()),
+		signer:  s,
+		allow:   allow,
+		timeout: *timeout,
+		log:     os.Stderr,
+		hosts:   append(listenHosts(*listen), hosts...),
+		origins: origins,
+	}
+
+	fmt.Fprintf(os.Stderr, "els-proxy: forwarding http://%s to %s://%s/%s as profile %q\n", *listen, px.caller.APIHandler.Scheme, px.caller.APIHandler.Domain, px.caller.APIHandler.Version, *profile)
+	fatal(http.ListenAndServe(*listen, px))
+}
+
+// listenHosts returns the values of the Host header of requests addressed to
+// the proxy listening on addr.
+func listenHosts(addr string) []string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return []string{addr}
+	}
+	hs := []string{addr}
+	if ip := net.ParseIP(host); host == "" || host == "localhost" || ip != nil && ip.IsLoopback() {
+		for _, h := range []string{"localhost", "127.0.0.1", "[::1]"} {
+			if hp := net.JoinHostPort(strings.Trim(h, "[]"), port); hp != addr {
+				hs = append(hs, hp)
+			}
+		}
+	}
+	return hs
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "els-proxy: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/elasticlic/els-api-sdk-go/els"
+	"golang.org/x/net/context"
+)
+
+// hopHeaders are the headers which apply to a single connection, so are not
+// forwarded by the proxy.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// signedHeaders are the headers set by signing, which are not forwarded from
+// the client.
+var signedHeaders = []string{
+	"Authorization",
+	"X-Els-Date",
+}
+
+// proxy is an http.Handler which forwards each request it receives to the ELS
+// as an ELS-signed API call.
+type proxy struct {
+	caller  *els.EDAPICaller
+	signer  els.Signer
+	allow   []string
+	timeout time.Duration
+	log     io.Writer
+
+	// hosts are the values of the Host header accepted, so that a page
+	// using DNS rebinding to reach the proxy under its own name is refused.
+	hosts []string
+
+	// origins are the origins (e.g. "http://127.0.0.1:8080") of the pages
+	// from which browsers may make requests through the proxy, in addition
+	// to the proxy itself.
+	origins []string
+}
+
+// ServeHTTP implements interface http.Handler.
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	status, n := p.serve(w, r)
+	fmt.Fprintf(p.log, "%s %s %s %s %d %d %s\n", start.Format(time.RFC3339), r.RemoteAddr, r.Method, r.URL.RequestURI(), status, n, time.Since(start))
+}
+
+// serve forwards r, writing the response to w, and returns the status and
+// number of body bytes of the response.
+func (p *proxy) serve(w http.ResponseWriter, r *http.Request) (int, int64) {
+	if !contains(p.hosts, r.Host) {
+		return p.fail(w, http.StatusForbidden, fmt.Errorf("host %s is not allowed", r.Host))
+	}
+	if o := requestOrigin(r); o != "" && !p.trusted(o) {
+		return p.fail(w, http.StatusForbidden, fmt.Errorf("origin %s is not allowed", o))
+	}
+	if !validRoute(r.URL) || !p.allowed(r.URL.Path) {
+		return p.fail(w, http.StatusForbidden, fmt.Errorf("route %s is not allowed", r.URL.EscapedPath()))
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), p.timeout)
+	defer cancel()
+
+	resp, err := p.caller.Do(ctx, newRequest(r), p.signer, true)
+	if err != nil {
+		return p.fail(w, http.StatusBadGateway, err)
+	}
+	defer resp.Body.Close()
+
+	copyHeader(w.Header(), resp.Header)
+	w.WriteHeader(resp.StatusCode)
+	n, _ := io.Copy(w, resp.Body)
+	return resp.StatusCode, n
+}
+
+// fail responds with status and the message of err.
+func (p *proxy) fail(w http.ResponseWriter, status int, err error) (int, int64) {
+	msg := "els-proxy: " + err.Error() + "\n"
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	n, _ := io.WriteString(w, msg)
+	return status, int64(n)
+}
+
+// requestOrigin returns the origin of the page which made request r, if it was
+// made by a browser: the Origin header or, for methods which may have side
+// effects, the origin of the Referer header if there is no Origin.
+func requestOrigin(r *http.Request) string {
+	if o := r.Header.Get("Origin"); o != "" {
+		return o
+	}
+	if r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS" {
+		return ""
+	}
+	ref, err := url.Parse(r.Header.Get("Referer"))
+	if err != nil || ref.Host == "" {
+		return ""
+	}
+	return ref.Scheme + "://" + ref.Host
+}
+
+// trusted returns true if requests may be made through the proxy by pages from
+// origin o: the proxy itself or one of the origins given.
+func (p *proxy) trusted(o string) bool {
+	for _, h := range p.hosts {
+		if o == "http://"+h {
+			return true
+		}
+	}
+	return contains(p.origins, o)
+}
+
+// validRoute returns true if the path of u has no dot-segments or encoded
+// slashes, which could be used to escape the allowed routes.
+func validRoute(u *url.URL) bool {
+	if !strings.HasPrefix(u.Path, "/") || strings.Contains(strings.ToLower(u.EscapedPath()), "%2f") {
+		return false
+	}
+	for _, seg := range strings.Split(u.Path[1:], "/") {
+		if seg == "." || seg == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// contains returns true if ss contains s.
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed returns true if route matches one of the allowed patterns, or no
+// patterns were given.
+func (p *proxy) allowed(route string) bool {
+	if len(p.allow) == 0 {
+		return true
+	}
+	for _, pattern := range p.allow {
+		if strings.HasSuffix(pattern, "/") && strings.HasPrefix(route, pattern) {
+			return true
+		}
+		if ok, _ := path.Match(pattern, route); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// newRequest returns the request to forward for the request r received by the
+// proxy. Its URL holds the route relative to the API version, ready to be
+// completed by the caller.
+func newRequest(r *http.Request) *http.Request {
+	out := &http.Request{
+		Method:        r.Method,
+		URL:           &url.URL{Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: r.URL.RawQuery},
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{},
+		Body:          r.Body,
+		ContentLength: r.ContentLength,
+	}
+	if r.ContentLength == 0 {
+		out.Body = nil
+	}
+
+	copyHeader(out.Header, r.Header)
+	for _, h := range signedHeaders {
+		out.Header.Del(h)
+	}
+	return out
+}
+
+// copyHeader copies the headers in src to dst, except the hop-by-hop headers.
+func copyHeader(dst http.Header, src http.Header) {
+	for k, vs := range src {
+		for _, v := range vs {
+			dst.Add(k, v)
+		}
+	}
+	for _, h := range hopHeaders {
+		dst.Del(h)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/elasticlic/els-api-sdk-go/els"
+	"github.com/elasticlic/els-api-sdk-go/els/elstest"
+	"github.com/elasticlic/go-utils/datetime"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Proxy Test Suite", func() {
+
+	var (
+		now, _ = time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+		k      = &els.AccessKey{ID: "id", SecretAccessKey: "secret"}
+		server *elstest.Server
+		sut    *proxy
+	)
+
+	BeforeEach(func() {
+		server = elstest.NewServer(k)
+		server.Now = func() time.Time { return now }
+		ok := func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}
+		server.HandleFunc("GET", "/users/a@b.com", ok)
+		server.HandleFunc("POST", "/users/a@b.com", ok)
+		server.HandleFunc("GET", "/vendors/v1", ok)
+
+		tp := datetime.NewNowTimeProvider()
+		tp.SetNow(now)
+		signer, err := els.NewAPISigner(k)
+		Expect(err).To(BeNil())
+		sut = &proxy{
+			caller:  server.NewAPICaller(tp),
+			signer:  signer,
+			allow:   []string{"/users/"},
+			timeout: time.Second,
+			log:     ioutil.Discard,
+			hosts:   listenHosts("127.0.0.1:8080"),
+			origins: []string{"http://admin.example.com"},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	serve := func(method string, target string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(""))
+		r.Host = "127.0.0.1:8080"
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		if h := header.Get("Host"); h != "" {
+			r.Host = h
+		}
+		w := httptest.NewRecorder()
+		sut.ServeHTTP(w, r)
+		return w
+	}
+
+	It("forwards allowed routes, signed", func() {
+		w := serve("GET", "/users/a@b.com", nil)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(Equal("ok"))
+		Expect(server.Requests()[0].Err).To(BeNil())
+	})
+
+	It("refuses routes which are not allowed", func() {
+		Expect(serve("GET", "/vendors/v1", nil).Code).To(Equal(http.StatusForbidden))
+		Expect(server.Requests()).To(BeEmpty())
+	})
+
+	It("refuses routes which escape the allowed routes", func() {
+		Expect(serve("GET", "/users/../vendors/v1", nil).Code).To(Equal(http.StatusForbidden))
+		Expect(serve("GET", "/users/%2e%2e/vendors/v1", nil).Code).To(Equal(http.StatusForbidden))
+		Expect(serve("GET", "/users/./a@b.com", nil).Code).To(Equal(http.StatusForbidden))
+		Expect(serve("GET", "/users/..%2Fvendors%2Fv1", nil).Code).To(Equal(http.StatusForbidden))
+		Expect(server.Requests()).To(BeEmpty())
+	})
+
+	It("accepts the listen address and localhost as the host", func() {
+		Expect(serve("GET", "/users/a@b.com", http.Header{"Host": {"localhost:8080"}}).Code).To(Equal(http.StatusOK))
+		Expect(serve("GET", "/users/a@b.com", http.Header{"Host": {"[::1]:8080"}}).Code).To(Equal(http.StatusOK))
+	})
+
+	It("refuses other hosts, such as those of DNS rebinding", func() {
+		Expect(serve("GET", "/users/a@b.com", http.Header{"Host": {"evil.example.com:8080"}}).Code).To(Equal(http.StatusForbidden))
+		Expect(serve("GET", "/users/a@b.com", http.Header{"Host": {"localhost:9090"}}).Code).To(Equal(http.StatusForbidden))
+		Expect(server.Requests()).To(BeEmpty())
+	})
+
+	It("refuses requests from pages of other origins", func() {
+		Expect(serve("POST", "/users/a@b.com", http.Header{"Origin": {"http://evil.example.com"}}).Code).To(Equal(http.StatusForbidden))
+		Expect(serve("GET", "/users/a@b.com", http.Header{"Origin": {"http://evil.example.com"}}).Code).To(Equal(http.StatusForbidden))
+		Expect(serve("POST", "/users/a@b.com", http.Header{"Referer": {"http://evil.example.com/page"}}).Code).To(Equal(http.StatusForbidden))
+		Expect(server.Requests()).To(BeEmpty())
+	})
+
+	It("accepts requests from the proxy's own origin and those given", func() {
+		Expect(serve("POST", "/users/a@b.com", http.Header{"Origin": {"http://127.0.0.1:8080"}}).Code).To(Equal(http.StatusOK))
+		Expect(serve("POST", "/users/a@b.com", http.Header{"Origin": {"http://admin.example.com"}}).Code).To(Equal(http.StatusOK))
+		Expect(serve("POST", "/users/a@b.com", http.Header{"Referer": {"http://localhost:8080/admin"}}).Code).To(Equal(http.StatusOK))
+		Expect(serve("GET", "/users/a@b.com", http.Header{"Referer": {"http://evil.example.com/page"}}).Code).To(Equal(http.StatusOK))
+	})
+
+	It("accepts requests from tools which send neither Origin nor Referer", func() {
+		Expect(serve("POST", "/users/a@b.com", nil).Code).To(Equal(http.StatusOK))
+	})
+})
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/elasticlic/els-api-sdk-go/els"
+	"github.com/elasticlic/go-utils/datetime"
+	"golang.org/x/net/context"
+)
+
+// errStatus is returned when the ELS responds with an error status, so that
+// the tool exits with a non-zero code once the response has been printed.
+var errStatus = errors.New("request failed")
+
+// env holds the state shared by all commands.
+type env struct {
+	configPath  string
+	profileName string
+	config      *els.ProfileConfig
+	profile     *els.Profile
+	caller      *els.EDAPICaller
+	tp          datetime.TimeProvider
+}
+
+// newEnv loads the named profile from the config file at path. A missing
+// profile is not an error at this point as create-key may be about to create
+// it.
+func newEnv(path string, profileName string) (*env, error) {
+	c, err := els.LoadProfileConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := c.Profile(profileName)
+	if err == els.ErrNoProfile {
+		p = &els.Profile{}
+	} else if err != nil {
+		return nil, err
+	}
+
+	tp := datetime.NewNowTimeProvider()
+	e := &env{
+		configPath:  path,
+		profileName: profileName,
+		config:      c,
+		profile:     p,
+		caller:      p.NewAPICaller(nil, tp),
+		tp:          tp,
+	}
+	return e, nil
+}
+
+// signer returns a signer for the profile's access key.
+func (e *env) signer() (*els.APISigner, error) {
+	if e.profile.AccessKey == nil {
+		return nil, fmt.Errorf("profile %q has no access key - use create-key -save", e.profileName)
+	}
+	return e.profile.Signer()
+}
+
+func runCreateKey(e *env, args []string) error {
+	fs := flag.NewFlagSet("create-key", flag.ExitOnError)
+	email := fs.String("email", "", "email address of the user")
+	password := fs.String("password", "", "password of the user")
+	prehashed := fs.Bool("prehashed", false, "the password has already been SHA-256 hashed")
+	days := fs.Uint("days", 0, "number of days until the key expires (0 = never)")
+	save := fs.Bool("save", false, "store the key in the profile")
+	fs.Parse(args)
+
+	if *email == "" || *password == "" {
+		return errors.New("create-key: -email and -password are required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.profile.Timeout())
+	defer cancel()
+
+	k, status, err := e.caller.CreateAccessKey(ctx, *email, *password, *prehashed, *days)
+	if err != nil {
+		if status != 0 {
+			return fmt.Errorf("create-key: %v (%d)", err, status)
+		}
+		return err
+	}
+
+	if *save {
+		e.profile.AccessKey = k
+		e.config.Profiles[e.profileName] = e.profile
+		if err = e.config.Save(e.configPath); err != nil {
+			return err
+		}
+	}
+
+	return printJSON(k)
+}
+
+func runSign(e *env, args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	method := fs.String("method", "GET", "http method of the request")
+	body := fs.String("body", "", "file containing the request body (- for stdin)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("sign: a route is required")
+	}
+
+	s, err := e.signer()
+	if err != nil {
+		return err
+	}
+
+	r, err := newRequest(*method, fs.Arg(0), *body)
+	if err != nil {
+		return err
+	}
+
+	// Sign the request exactly as it would be sent.
+	req, err := e.caller.DryRun(nil, r, els.WithSigner(s))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(req.Method, req.URL)
+	return req.Header.Write(os.Stdout)
+}
+
+func runPresign(e *env, args []string) error {
+	fs := flag.NewFlagSet("presign", flag.ExitOnError)
+	method := fs.String("method", "GET", "http method the URL will be used with")
+	ttl := fs.Duration("ttl", time.Hour, "how long the URL remains valid")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("presign: a route is required")
+	}
+
+	s, err := e.signer()
+	if err != nil {
+		return err
+	}
+
+	r, err := newRequest(*method, fs.Arg(0), "")
+	if err != nil {
+		return err
+	}
+
+	e.caller.APIHandler.CompleteURL(r.URL)
+
+	u, err := s.Presign(r, e.tp.Now(), *ttl)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(u)
+	return nil
+}
+
+// runCall returns a command which sends a signed request with the given
+// method.
+func runCall(method string) func(e *env, args []string) error {
+	return func(e *env, args []string) error {
+		fs := flag.NewFlagSet(method, flag.ExitOnError)
+		var body *string
+		if method == "POST" || method == "PUT" {
+			body = fs.String("body", "", "file containing the request body (- for stdin)")
+		} else {
+			body = new(string)
+		}
+		export := fs.String("export", "", "print the signed request as a curl or httpie command or a har file instead of sending it")
+		redact := fs.Bool("redact", false, "redact secrets from the exported request")
+		fs.Parse(args)
+
+		if fs.NArg() != 1 {
+			return errors.New("a route is required")
+		}
+
+		s, err := e.signer()
+		if err != nil {
+			return err
+		}
+
+		r, err := newRequest(method, fs.Arg(0), *body)
+		if err != nil {
+			return err
+		}
+
+		if *export != "" {
+			return exportRequest(e, r, s, *export, *redact)
+		}
+
+		rep, err := e.caller.Do(nil, r, s, true)
+		if err != nil {
+			return err
+		}
+		defer rep.Body.Close()
+
+		return printResponse(rep)
+	}
+}
+
+// exportRequest completes and signs r without sending it, and prints it in the
+// given format: curl, httpie or har.
+func exportRequest(e *env, r *http.Request, s els.Signer, format string, redact bool) error {
+	req, err := e.caller.DryRun(nil, r, els.WithSigner(s))
+	if err != nil {
+		return err
+	}
+
+	var opts []els.ExportOption
+	if redact {
+		opts = append(opts, els.Redacted(s))
+	}
+
+	var out string
+	switch format {
+	case "curl":
+		out, err = els.CurlCommand(req, opts...)
+	case "httpie":
+		out, err = els.HTTPieCommand(req, opts...)
+	case "har":
+		entry, herr := els.NewHAREntry(req, opts...)
+		if herr != nil {
+			return herr
+		}
+		return printJSON(els.NewHAR(entry))
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(out)
+	return nil
+}
+
+// newRequest builds a request for the route, streaming the body from the named
+// file (or stdin if "-"). Leave bodyFile blank to send no body.
+func newRequest(method string, route string, bodyFile string) (*http.Request, error) {
+
+	if bodyFile == "" {
+		return http.NewRequest(method, route, nil)
+	}
+
+	if bodyFile == "-" {
+		return http.NewRequest(method, route, os.Stdin)
+	}
+
+	f, err := os.Open(bodyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r, err := http.NewRequest(method, route, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.ContentLength = fi.Size()
+
+	return r, nil
+}
+
+// printResponse writes the status line to stderr and the body to stdout,
+// pretty-printing it if it's JSON.
+func printResponse(rep *http.Response) error {
+	fmt.Fprintln(os.Stderr, rep.Proto, rep.Status)
+
+	content, err := ioutil.ReadAll(rep.Body)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if json.Indent(&out, content, "", "  ") == nil {
+		content = append(out.Bytes(), '\n')
+	}
+	os.Stdout.Write(content)
+
+	if rep.StatusCode >= 400 {
+		return errStatus
+	}
+	return nil
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", content)
+	return nil
+}
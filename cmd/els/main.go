@@ -0,0 +1,103 @@
+/*
+Command els makes ELS-signed API calls from the command line using the
+profiles stored in the ELS profile config file ($HOME/.els/config.json by
+default).
+
+Usage:
+
+	els [flags] <command> [command flags] [args]
+
+The commands are:
+
+	create-key  request a new access key from the ELS, optionally saving it
+	            to the profile
+	sign        sign a request and print the headers without sending it
+	presign     print a URL carrying a time-limited signature for a route
+	get         send a signed GET request to an ELS route
+	post        send a signed POST request to an ELS route
+	put         send a signed PUT request to an ELS route
+	delete      send a signed DELETE request to an ELS route
+
+Routes are given relative to the API version, e.g. "/users/a@b.com". JSON
+responses are pretty-printed to stdout; the status line is written to stderr.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/elasticlic/els-api-sdk-go/els"
+	log "github.com/sirupsen/logrus"
+)
+
+// command is a subcommand of the els tool.
+type command struct {
+	name  string
+	usage string
+	run   func(e *env, args []string) error
+}
+
+var commands = []*command{
+	{"create-key", "-email <email> -password <password> [-prehashed] [-days <n>] [-save]", runCreateKey},
+	{"sign", "[-method <method>] [-body <file>] <route>", runSign},
+	{"presign", "[-method <method>] [-ttl <duration>] <route>", runPresign},
+	{"get", "[-export <format> [-redact]] <route>", runCall("GET")},
+	{"post", "[-body <file>] [-export <format> [-redact]] <route>", runCall("POST")},
+	{"put", "[-body <file>] [-export <format> [-redact]] <route>", runCall("PUT")},
+	{"delete", "[-export <format> [-redact]] <route>", runCall("DELETE")},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: els [flags] <command> [command flags] [args]\n\nFlags:\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-11s %s\n", c.name, c.usage)
+	}
+}
+
+func main() {
+	configPath := flag.String("config", els.DefaultProfileConfigPath(), "path of the profile config file")
+	profile := flag.String("profile", els.DefaultProfileName, "name of the profile to use")
+	verbose := flag.Bool("v", false, "log debug output to stderr")
+	flag.Usage = usage
+	flag.Parse()
+
+	log.SetOutput(ioutil.Discard)
+	if *verbose {
+		log.SetOutput(os.Stderr)
+		log.SetLevel(log.DebugLevel)
+	}
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	e, err := newEnv(*configPath, *profile)
+	if err != nil {
+		fatal(err)
+	}
+
+	name := flag.Arg(0)
+	for _, c := range commands {
+		if c.name == name {
+			if err = c.run(e, flag.Args()[1:]); err != nil {
+				fatal(err)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "els: unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "els: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,385 @@
+package els
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AgentSocketEnv is the environment variable which holds the path of the Unix
+// socket of the signing agent, if set.
+const AgentSocketEnv = "ELS_AGENT_SOCK"
+
+// DefaultAgentTimeout limits each exchange between an AgentSigner and the
+// agent.
+const DefaultAgentTimeout = 5 * time.Second
+
+// The operations supported by an Agent.
+const (
+	agentOpList = "list"
+	agentOpSign = "sign"
+)
+
+var (
+	ErrAgentOperation = errors.New("Unsupported Agent Operation")
+	ErrAgentClosed    = errors.New("Agent Closed")
+)
+
+// agentErrors are the errors which an Agent may return, so that an
+// AgentSigner can return the same values.
+var agentErrors = []error{ErrUnknownAccessKey, ErrAgentOperation}
+
+// AgentKey describes an access key held by an Agent, without its secret.
+type AgentKey struct {
+	ID         AccessKeyID `json:"accessKeyId"`
+	ExpiryDate time.Time   `json:"expiryDt"`
+	Email      string      `json:"emailAddress,omitempty"`
+}
+
+// agentRequest is a request sent to an Agent. Each connection carries a
+// sequence of JSON-encoded requests, each answered by an agentResponse.
+type agentRequest struct {
+	Op           string      `json:"op"`
+	AccessKeyID  AccessKeyID `json:"accessKeyId,omitempty"`
+	StringToSign string      `json:"stringToSign,omitempty"`
+}
+
+// agentResponse is the response of an Agent to an agentRequest.
+type agentResponse struct {
+	Keys      []AgentKey `json:"keys,omitempty"`
+	Signature string     `json:"signature,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// DefaultAgentSocketPath returns the path of the agent's socket given by
+// AgentSocketEnv, or if that is not set, $HOME/.els/agent.sock.
+func DefaultAgentSocketPath() string {
+	if p := os.Getenv(AgentSocketEnv); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return filepath.Join(home, ".els", "agent.sock")
+}
+
+// Agent holds access keys and signs strings with them on behalf of
+// AgentSigners which connect to it over a Unix socket, so that the secrets of
+// the keys need only be held by the process running the agent (see the
+// els-agent command). Anything able to connect to the socket can sign with the
+// keys, so it should only be accessible to the user.
+type Agent struct {
+	mu   sync.RWMutex
+	keys map[AccessKeyID]*AccessKey
+
+	lmu       sync.Mutex
+	listeners map[net.Listener]bool
+	closed    bool
+}
+
+// NewAgent returns an Agent holding no keys.
+func NewAgent() *Agent {
+	return &Agent{
+		keys:      map[AccessKeyID]*AccessKey{},
+		listeners: map[net.Listener]bool{},
+	}
+}
+
+// Add adds access key k to the agent, replacing any with the same ID.
+func (g *Agent) Add(k *AccessKey) error {
+	if k == nil {
+		return ErrNoAccessKey
+	}
+	if !k.CanSign() {
+		return ErrInvalidAccessKey
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c := *k
+	g.keys[k.ID] = &c
+	return nil
+}
+
+// Remove removes the access key with the given ID from the agent.
+func (g *Agent) Remove(id AccessKeyID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.keys, id)
+}
+
+// Keys returns the keys held by the agent, sorted by ID.
+func (g *Agent) Keys() []AgentKey {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	ks := make([]AgentKey, 0, len(g.keys))
+	for _, k := range g.keys {
+		ks = append(ks, AgentKey{ID: k.ID, ExpiryDate: k.ExpiryDate, Email: k.Email})
+	}
+	sort.Slice(ks, func(i, j int) bool { return ks[i].ID < ks[j].ID })
+	return ks
+}
+
+// ListenAndServe listens on the Unix socket at path, which is made accessible
+// only to the user, and serves requests until Close is called. Any stale socket
+// at path is removed first.
+func (g *Agent) ListenAndServe(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	l, err := listenUnix(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return err
+	}
+
+	return g.Serve(l)
+}
+
+// Serve accepts connections on l and serves requests on them until Close is
+// called, when it returns nil.
+func (g *Agent) Serve(l net.Listener) error {
+	g.lmu.Lock()
+	if g.closed {
+		g.lmu.Unlock()
+		l.Close()
+		return ErrAgentClosed
+	}
+	g.listeners[l] = true
+	g.lmu.Unlock()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			g.lmu.Lock()
+			closed := g.closed
+			delete(g.listeners, l)
+			g.lmu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		go g.serveConn(c)
+	}
+}
+
+// Close stops the agent accepting connections.
+func (g *Agent) Close() error {
+	g.lmu.Lock()
+	defer g.lmu.Unlock()
+
+	g.closed = true
+	for l := range g.listeners {
+		l.Close()
+	}
+	return nil
+}
+
+// serveConn answers the requests received on connection c until it is closed.
+func (g *Agent) serveConn(c net.Conn) {
+	defer c.Close()
+
+	dec := json.NewDecoder(c)
+	enc := json.NewEncoder(c)
+	for {
+		req := agentRequest{}
+		if err := dec.Decode(&req); err != nil {
+			if err != io.EOF {
+				log.WithFields(log.Fields{"Time": time.Now(), "err": err}).Debug("Agent: Bad request")
+			}
+			return
+		}
+		if err := enc.Encode(g.handle(&req)); err != nil {
+			return
+		}
+	}
+}
+
+// handle returns the response to req.
+func (g *Agent) handle(req *agentRequest) *agentResponse {
+	switch req.Op {
+	case agentOpList:
+		return &agentResponse{Keys: g.Keys()}
+	case agentOpSign:
+		g.mu.RLock()
+		k := g.keys[req.AccessKeyID]
+		g.mu.RUnlock()
+		if k == nil {
+			return &agentResponse{Error: ErrUnknownAccessKey.Error()}
+		}
+		log.WithFields(log.Fields{"Time": time.Now(), "accessKeyId": k.ID}).Debug("Agent: Signing")
+		return &agentResponse{Signature: signature(k.SecretAccessKey, req.StringToSign)}
+	}
+	return &agentResponse{Error: ErrAgentOperation.Error()}
+}
+
+// AgentSigner implements the Signer interface by signing requests with an
+// access key held by an Agent, so that the secret of the key never enters the
+// process. It otherwise signs requests exactly as an APISigner would.
+type AgentSigner struct {
+	// Timeout limits each exchange with the agent.
+	Timeout time.Duration
+
+	path string
+	key  AgentKey
+
+	scheme        SigningScheme
+	signedHeaders []string
+	versions      []string
+}
+
+// NewAgentSigner returns an AgentSigner which signs requests using SchemeV1
+// with the access key with the given ID, held by the agent listening on the
+// Unix socket at path (see DefaultAgentSocketPath). ErrUnknownAccessKey is
+// returned if the agent does not hold the key.
+func NewAgentSigner(path string, id AccessKeyID) (*AgentSigner, error) {
+	s := &AgentSigner{
+		Timeout: DefaultAgentTimeout,
+		path:    path,
+	}
+
+	rep, err := s.call(&agentRequest{Op: agentOpList})
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range rep.Keys {
+		if k.ID == id {
+			s.key = k
+			return s, nil
+		}
+	}
+
+	return nil, ErrUnknownAccessKey
+}
+
+// NewAgentSignerV2 is like NewAgentSigner, but the returned signer uses
+// SchemeV2, signing the given headers as NewAPISignerV2 does.
+func NewAgentSignerV2(path string, id AccessKeyID, signedHeaders ...string) (*AgentSigner, error) {
+	hs, err := canonicalSignedHeaders(signedHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := NewAgentSigner(path, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.scheme = SchemeV2
+	s.signedHeaders = hs
+
+	return s, nil
+}
+
+// AccessKeyID returns the ID of the access key used to sign requests.
+func (s *AgentSigner) AccessKeyID() AccessKeyID {
+	return s.key.ID
+}
+
+// ExpiryDate returns the expiry date of the access key used to sign requests,
+// or the zero time if it never expires.
+func (s *AgentSigner) ExpiryDate() time.Time {
+	return s.key.ExpiryDate
+}
+
+// Scheme returns the signing scheme used by the signer.
+func (s *AgentSigner) Scheme() SigningScheme {
+	return s.scheme
+}
+
+// SetAPIVersions sets the API versions to which the signer signs requests, as
+// APISigner.SetAPIVersions does.
+func (s *AgentSigner) SetAPIVersions(vs ...string) {
+	s.versions = vs
+}
+
+// APIVersions returns the API versions set with SetAPIVersions, or nil if
+// none are set and only DefaultAPIVersion is signed.
+func (s *AgentSigner) APIVersions() []string {
+	return s.versions
+}
+
+// Sign implements interface Signer, signing request r at time now. Only the
+// string to sign is sent to the agent.
+func (s *AgentSigner) Sign(r *http.Request, now time.Time) error {
+	return s.SignVersion(r, now, "")
+}
+
+// SignVersion implements interface VersionSigner, signing request r as Sign
+// does, but also accepting requests to the given API version if none are set
+// with SetAPIVersions.
+func (s *AgentSigner) SignVersion(r *http.Request, now time.Time, version string) error {
+
+	if r == nil {
+		return ErrNoRequest
+	}
+
+	if !validPath(r.URL.Path, signableVersions(s.versions, version)) {
+		return ErrRequestInvalidURL
+	}
+
+	k := AccessKey{ID: s.key.ID, ExpiryDate: s.key.ExpiryDate}
+
+	if !k.ValidUntil(now, time.Minute) {
+		return ErrExpiredAccessKey
+	}
+
+	utcStr := now.UTC().Format(time.RFC3339)
+
+	return signWith(r, utcStr, s.scheme, s.signedHeaders, k.ID, func(fingerprint string) (string, error) {
+		rep, err := s.call(&agentRequest{Op: agentOpSign, AccessKeyID: k.ID, StringToSign: fingerprint})
+		if err != nil {
+			return "", err
+		}
+		return rep.Signature, nil
+	})
+}
+
+// call sends req to the agent and returns its response. An error reported by
+// the agent is returned as the corresponding error value.
+func (s *AgentSigner) call(req *agentRequest) (*agentResponse, error) {
+	c, err := net.DialTimeout("unix", s.path, s.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(s.Timeout))
+
+	if err := json.NewEncoder(c).Encode(req); err != nil {
+		return nil, err
+	}
+	rep := &agentResponse{}
+	if err := json.NewDecoder(c).Decode(rep); err != nil {
+		return nil, err
+	}
+
+	if rep.Error != "" {
+		for _, e := range agentErrors {
+			if rep.Error == e.Error() {
+				return nil, e
+			}
+		}
+		return nil, errors.New(rep.Error)
+	}
+
+	return rep, nil
+}
@@ -0,0 +1,133 @@
+package els
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Agent Test Suite", func() {
+
+	var (
+		now, _ = time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+		k      *AccessKey
+		agent  *Agent
+		dir    string
+		path   string
+		served chan error
+
+		newRequest = func() *http.Request {
+			r, err := http.NewRequest("POST", "https://api.elasticlicensing.com/1.0/vendors/v1?a=1", bytes.NewBufferString(`{"a":1}`))
+			Expect(err).To(BeNil())
+			r.Header.Set("X-Request-Id", "abc")
+			return r
+		}
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "els-agent")
+		Expect(err).To(BeNil())
+		path = filepath.Join(dir, "agent.sock")
+
+		k = &AccessKey{ID: "AccessKeyID", SecretAccessKey: "secretAccessKey", ExpiryDate: now.Add(time.Hour)}
+		agent = NewAgent()
+		Expect(agent.Add(k)).To(Succeed())
+
+		served = make(chan error, 1)
+		go func() {
+			served <- agent.ListenAndServe(path)
+		}()
+		Eventually(func() error {
+			_, err := os.Stat(path)
+			return err
+		}).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		agent.Close()
+		Eventually(served).Should(Receive(BeNil()))
+		os.RemoveAll(dir)
+	})
+
+	It("makes the socket accessible only to the user", func() {
+		fi, err := os.Stat(path)
+		Expect(err).To(BeNil())
+		Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("creates sockets accessible only to the user", func() {
+		p := filepath.Join(dir, "other.sock")
+		l, err := listenUnix(p)
+		Expect(err).To(BeNil())
+		defer l.Close()
+		fi, err := os.Stat(p)
+		Expect(err).To(BeNil())
+		Expect(fi.Mode().Perm() & 0077).To(BeZero())
+	})
+
+	It("lists its keys without their secrets", func() {
+		Expect(agent.Add(&AccessKey{ID: "Other"})).To(Equal(ErrInvalidAccessKey))
+		Expect(agent.Keys()).To(Equal([]AgentKey{{ID: k.ID, ExpiryDate: k.ExpiryDate}}))
+	})
+
+	Describe("AgentSigner", func() {
+		It("signs requests as an APISigner would", func() {
+			sut, err := NewAgentSigner(path, k.ID)
+			Expect(err).To(BeNil())
+			Expect(sut.AccessKeyID()).To(Equal(k.ID))
+			Expect(sut.ExpiryDate()).To(Equal(k.ExpiryDate))
+
+			expected, err := NewAPISigner(k)
+			Expect(err).To(BeNil())
+
+			r, e := newRequest(), newRequest()
+			Expect(sut.Sign(r, now)).To(Succeed())
+			Expect(expected.Sign(e, now)).To(Succeed())
+			Expect(r.Header).To(Equal(e.Header))
+		})
+
+		It("signs requests with SchemeV2", func() {
+			sut, err := NewAgentSignerV2(path, k.ID, "X-Request-Id")
+			Expect(err).To(BeNil())
+			Expect(sut.Scheme()).To(Equal(SchemeV2))
+
+			expected, err := NewAPISignerV2(k, "X-Request-Id")
+			Expect(err).To(BeNil())
+
+			r, e := newRequest(), newRequest()
+			Expect(sut.Sign(r, now)).To(Succeed())
+			Expect(expected.Sign(e, now)).To(Succeed())
+			Expect(r.Header).To(Equal(e.Header))
+		})
+
+		It("returns ErrUnknownAccessKey if the agent doesn't hold the key", func() {
+			_, err := NewAgentSigner(path, "Other")
+			Expect(err).To(Equal(ErrUnknownAccessKey))
+
+			sut, err := NewAgentSigner(path, k.ID)
+			Expect(err).To(BeNil())
+			agent.Remove(k.ID)
+			Expect(sut.Sign(newRequest(), now)).To(Equal(ErrUnknownAccessKey))
+		})
+
+		It("returns ErrExpiredAccessKey if the key has expired", func() {
+			sut, err := NewAgentSigner(path, k.ID)
+			Expect(err).To(BeNil())
+			Expect(sut.Sign(newRequest(), now.Add(time.Hour))).To(Equal(ErrExpiredAccessKey))
+		})
+
+		It("fails if the agent isn't running", func() {
+			sut, err := NewAgentSigner(path, k.ID)
+			Expect(err).To(BeNil())
+			sut.path = filepath.Join(dir, "none.sock")
+			Expect(sut.Sign(newRequest(), now)).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package els
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenUnix listens on the Unix socket at path, creating it accessible only to
+// the user so that no other user can connect before its mode is set. The umask
+// is process-wide, so files created concurrently by other goroutines are also
+// made private.
+func listenUnix(path string) (net.Listener, error) {
+	old := syscall.Umask(0077)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,9 @@
+package els
+
+import "net"
+
+// listenUnix listens on the Unix socket at path, whose access is controlled by
+// the permissions of its directory.
+func listenUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
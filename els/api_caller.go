@@ -33,6 +33,15 @@ type APICaller interface {
 	// ctx.Err().
 	Get(ctx context.Context, url string, s Signer, isELSAPI bool) (*http.Response, error)
 
+	// DoWith executes the request as Do does, with its signer, timeout and
+	// other settings given by options (see CallOption) rather than positional
+	// arguments. ctx may be nil or have no deadline, in which case the
+	// default timeout applies.
+	DoWith(ctx context.Context, r *http.Request, opts ...CallOption) (*http.Response, error)
+
+	// GetWith executes an HTTP GET request with the given url as DoWith does.
+	GetWith(ctx context.Context, url string, opts ...CallOption) (*http.Response, error)
+
 	// LastTimeout returns the time when an API call last failed to connect. If
 	// there have been no timeouts, it will return the zero time (time.Time{})
 	LastTimeout() time.Time
@@ -59,6 +68,38 @@ type EDAPICaller struct {
 	// requestTimeout governs how long to wait after making an API call before
 	// giving up on the response.
 	requestTimeout time.Duration
+
+	// clockOffset is the latest estimate of how far the ELS clock is ahead of
+	// the local clock (see ClockOffset).
+	clockOffset time.Duration
+
+	// correctClock determines whether clockOffset is applied to the time used
+	// to sign requests.
+	correctClock bool
+
+	// observers are notified of the progress of each API call.
+	observers []CallObserver
+
+	// interceptors are run at their stage of each API call, after the
+	// built-in interceptors.
+	interceptors []stagedInterceptor
+
+	// cache, if set, stores the responses to GET requests.
+	cache Cache
+
+	// flights holds the GET requests in flight, if coalescing is enabled.
+	flights *flightGroup
+
+	// endpoints, if set, are the ELS endpoints to which ELS API calls are
+	// sent, instead of that of the APIHandler.
+	endpoints *endpointSet
+
+	// services are the third-party APIs added with AddService, by name.
+	services map[string]Service
+
+	// noIdempotencyKeys is true if idempotency keys are not added to requests
+	// (see SetIdempotencyKeys).
+	noIdempotencyKeys bool
 }
 
 // NewEDAPICaller returns an EDAPICaller which will sign http.Requests and send them
@@ -87,48 +128,172 @@ func (a *EDAPICaller) LastTimeout() time.Time {
 	return a.lastTimeout
 }
 
-// Do completes the url of the request, signs the request and executes it. If
+// Do completes the url of the request, signs the request and executes it,
+// running any interceptors added with Use (see Stage). If
 // the context has a deadline which expires, then context.DeadlineExceeded will
 // be returned.
 // Pass nil as ctx if you want a default context which times-out
 // after the default ELS-signed API call timeout. Pass nil as s if you don't
 // want the API call to be ELS-signed. Pass false as isELSAPI if the request
 // is a call to a third-party API.
+// If clock correction is enabled (see SetClockCorrection) and the ELS rejects
+// the signature of a request because of the time it was signed, the request
+// is re-signed with the corrected time and sent again, once.
+// If a cache is set (see SetCache), GET responses may be served from it, and
+// if coalescing is enabled (see SetCoalescing), identical concurrent GET
+// requests share a response.
+//
+// See DoWith for a more flexible way to make calls.
 func (a *EDAPICaller) Do(ctx context.Context, r *http.Request, s Signer, isELSAPI bool) (*http.Response, error) {
+	return a.doWith(ctx, r, NewCallOptions(legacyOptions(s, isELSAPI)...), OpDo)
+}
+
+// do makes an attempt at call c with request r and options o, coalescing it
+// with identical calls if enabled.
+func (a *EDAPICaller) do(ctx context.Context, c *Call, r *http.Request, o CallOptions) (*http.Response, error) {
+	if g := a.getFlights(); g != nil && !o.NoCache && dryRunFromContext(ctx) == nil {
+		if key, ok := coalesceKey(r, o.Signer, o.IsELSAPI); ok {
+			return a.coalesce(ctx, c, g, key, r, o)
+		}
+	}
+	return a.call(ctx, c, r, o)
+}
+
+// call makes an attempt at call c with request r and options o, recording it
+// in c.
+func (a *EDAPICaller) call(ctx context.Context, c *Call, r *http.Request, o CallOptions) (resp *http.Response, err error) {
+
+	s, isELSAPI := o.Signer, o.IsELSAPI
+	e := &Exchange{Call: c, Request: r, Signer: s, IsELSAPI: isELSAPI}
+
+	if err = a.intercept(ctx, StageBeforeSign, e); err != nil {
+		return nil, err
+	}
 
-	cancel := func() {}
-	if ctx == nil {
-		ctx, cancel = context.WithTimeout(context.Background(), a.requestTimeout)
+	cache := a.getCache()
+	if o.NoCache || dryRunFromContext(ctx) != nil {
+		cache = nil
+	}
+	var key string
+	var cached *CachedResponse
+	if cache != nil {
+		var ok bool
+		if key, ok = cacheKey(r, s); !ok {
+			cache = nil
+		} else if cached = cache.Get(key); cached != nil {
+			if resp = fromCache(r, cached, a.tp.Now()); resp != nil {
+				c.FromCache = true
+				e.Response = resp
+				if err = a.intercept(ctx, StageAfterResponse, e); err != nil {
+					e.Response.Body.Close()
+					return nil, err
+				}
+				return e.Response, nil
+			}
+			// Revalidate the response without modifying the caller's request.
+			r = revalidation(ctx, r, cached)
+			e.Request = r
+		}
+	}
+
+	if eps := a.getEndpoints(); eps != nil && isELSAPI {
+		resp, err = a.failover(ctx, eps, e, r)
+	} else {
+		resp, err = a.send(ctx, e, r)
 	}
-	defer cancel()
 
-	if isELSAPI {
-		u := r.URL
-		u.Scheme = a.APIHandler.Scheme
-		u.Host = a.APIHandler.Domain
-		u.Path = "/" + a.APIHandler.Version + u.Path
+	if cache != nil && err == nil {
+		resp = updateCache(cache, key, cached, resp, a.tp.Now())
 	}
 
-	// ELS-Sign the request
-	if s != nil {
-		if err := s.Sign(r, a.tp.Now()); err != nil {
-			log.WithFields(log.Fields{"Time": time.Now(), "err": err}).Debug("ApiCaller: Failed to sign")
+	return resp, err
+}
+
+// send signs and sends request r for exchange e. If the ELS rejects the
+// signature because of the time it was signed and clock correction is on, it
+// is re-signed with the corrected time and sent again, once.
+func (a *EDAPICaller) send(ctx context.Context, e *Exchange, r *http.Request) (resp *http.Response, err error) {
+	offset := a.signingOffset()
+	resp, err = a.signAndSend(ctx, e, r, offset)
+
+	if err == nil && e.Signer != nil && e.IsELSAPI && a.isClockRejection(resp, offset) && canResend(r) {
+		log.WithFields(log.Fields{"Time": time.Now(), "offset": a.ClockOffset()}).Debug("ApiCaller: Re-signing with corrected time")
+		resp.Body.Close()
+		if err = resetBody(r); err != nil {
 			return nil, err
 		}
+		resp, err = a.signAndSend(ctx, e, r, a.ClockOffset())
+	}
+
+	return resp, err
+}
+
+// resetBody resets the body of r from GetBody (if set) so that it can be sent
+// again.
+func resetBody(r *http.Request) (err error) {
+	if r.GetBody != nil {
+		r.Body, err = r.GetBody()
+	}
+	return err
+}
+
+// signAndSend makes an attempt at the call of exchange e with request r: it
+// signs a copy of r (if it has a signer) with the time adjusted by offset,
+// runs the after-sign interceptors, executes it and runs the after-response
+// interceptors. Signing a copy leaves r unsigned, so that it can be signed
+// afresh if sent again. The server time reported by the ELS is used to update
+// the clock offset estimate.
+func (a *EDAPICaller) signAndSend(ctx context.Context, e *Exchange, r *http.Request, offset time.Duration) (*http.Response, error) {
+
+	r = copyRequest(ctx, r)
+	e.Request = r
+	e.sendFailed = false
+	a.beforeSend(ctx, e.Call, r)
+
+	// ELS-Sign the request, then run the after-sign interceptors
+	e.SignTime = a.tp.Now().Add(offset)
+	e.Response = nil
+	if err := a.intercept(ctx, StageAfterSign, e); err != nil {
+		return nil, err
+	}
+	if d := dryRunFromContext(ctx); d != nil {
+		d.request = r
+		return nil, errDryRun
+	}
+	if rr := responseFromContext(ctx); rr != nil {
+		rr.SignedRequest = r
 	}
 	log.WithFields(log.Fields{"Time": time.Now(), "request": r}).Debug("ApiCaller: Do")
+	sent := a.tp.Now()
+	start := time.Now()
 	resp, err := ctxhttp.Do(ctx, a.APIHandler.Client, r)
+	e.Call.AttemptDurations = append(e.Call.AttemptDurations, time.Since(start))
 
 	if err != nil {
 		t := a.tp.Now()
 		a.Lock()
 		a.lastTimeout = t
 		a.Unlock()
+		e.Call.TimedOut = true
+		e.sendFailed = true
 		log.WithFields(log.Fields{"Time": t, "err": err, "response": resp}).Debug("ApiCaller: Timed out")
+		return resp, err
+	}
+
+	if e.IsELSAPI {
+		a.recordServerTime(resp, sent, a.tp.Now())
 	}
 	log.WithFields(log.Fields{"Time": time.Now(), "err": err, "response": resp}).Debug("ApiCaller: Response")
 
-	return resp, err
+	e.Response = resp
+	if err = a.intercept(ctx, StageAfterResponse, e); err != nil {
+		if e.Response != nil {
+			e.Response.Body.Close()
+		}
+		return nil, err
+	}
+
+	return e.Response, nil
 }
 
 // Get creates a signed GET request with a completed version of the url and
@@ -141,5 +306,29 @@ func (a *EDAPICaller) Get(ctx context.Context, url string, s Signer, isELSAPI bo
 	if err != nil {
 		return nil, err
 	}
-	return a.Do(ctx, r, s, isELSAPI)
+	return a.doWith(ctx, r, NewCallOptions(legacyOptions(s, isELSAPI)...), OpGet)
+}
+
+// CreateAccessKey implements interface APIUtils by calling
+// APIHandler.CreateAccessKey, reporting the call to any observers.
+func (a *EDAPICaller) CreateAccessKey(ctx context.Context, emailAddress string, password string, pwPrehashed bool, expiryDays uint) (*AccessKey, int, error) {
+
+	c := &Call{
+		Operation: OpCreateAccessKey,
+		Method:    "POST",
+		Route:     "/users/{email}/accessKeys",
+		IsELSAPI:  true,
+		Start:     time.Now(),
+	}
+	ctx = a.callStarted(ctx, c)
+
+	k, statusCode, err := a.APIHandler.createAccessKey(ctx, emailAddress, password, pwPrehashed, expiryDays, func(r *http.Request) {
+		a.setIdempotencyKey(r, true, IdempotencyKeyFromContext(ctx))
+		a.beforeSend(ctx, c, r)
+	})
+
+	c.StatusCode = statusCode
+	a.callFinished(ctx, c, nil, err)
+
+	return k, statusCode, err
 }
@@ -0,0 +1,257 @@
+package els
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// DefaultAPIKeyHeader is the header in which APIKeyAuth sends the key if no
+// header is given.
+const DefaultAPIKeyHeader = "X-Api-Key"
+
+// Defaults for the settings of OAuth2ClientCredentials.
+const (
+	DefaultTokenTimeout       = 10 * time.Second
+	DefaultTokenRefreshMargin = 30 * time.Second
+)
+
+var (
+	ErrTokenRequestFailed = errors.New("Token Request Failed")
+	ErrNoToken            = errors.New("No Token")
+)
+
+// The authentication strategies below implement the Signer interface, so
+// that they can authenticate calls to third-party APIs made with Do (passing
+// false as isELSAPI) or with a Service.
+
+// BearerAuth authenticates requests with a bearer token.
+type BearerAuth struct {
+	Token string
+}
+
+// Sign implements interface Signer by setting the Authorization header.
+func (a *BearerAuth) Sign(r *http.Request, now time.Time) error {
+	if r == nil {
+		return ErrNoRequest
+	}
+	r.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+// BasicAuth authenticates requests with HTTP basic authentication.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Sign implements interface Signer by setting the Authorization header.
+func (a *BasicAuth) Sign(r *http.Request, now time.Time) error {
+	if r == nil {
+		return ErrNoRequest
+	}
+	r.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// APIKeyAuth authenticates requests with an API key sent in a header, or in a
+// query parameter if QueryParam is set.
+type APIKeyAuth struct {
+	Key string
+
+	// Header is the header in which to send the key. If empty,
+	// DefaultAPIKeyHeader is used.
+	Header string
+
+	// QueryParam, if set, is the query parameter in which to send the key
+	// instead of a header.
+	QueryParam string
+}
+
+// Sign implements interface Signer by setting the header or query parameter.
+func (a *APIKeyAuth) Sign(r *http.Request, now time.Time) error {
+	if r == nil {
+		return ErrNoRequest
+	}
+
+	if a.QueryParam != "" {
+		q := r.URL.Query()
+		q.Set(a.QueryParam, a.Key)
+		r.URL.RawQuery = q.Encode()
+		return nil
+	}
+
+	r.Header.Set(a.header(), a.Key)
+	return nil
+}
+
+// header returns the header in which to send the key.
+func (a *APIKeyAuth) header() string {
+	if a.Header == "" {
+		return DefaultAPIKeyHeader
+	}
+	return a.Header
+}
+
+// SecretHeaders implements interface SecretInfo, returning the header in which
+// the key is sent, if any.
+func (a *APIKeyAuth) SecretHeaders() []string {
+	if a.QueryParam != "" {
+		return nil
+	}
+	return []string{a.header()}
+}
+
+// SecretQueryParams implements interface SecretInfo, returning the query
+// parameter in which the key is sent, if any.
+func (a *APIKeyAuth) SecretQueryParams() []string {
+	if a.QueryParam == "" {
+		return nil
+	}
+	return []string{a.QueryParam}
+}
+
+// OAuth2ClientCredentials authenticates requests with a bearer token obtained
+// from an OAuth2 token endpoint using the client credentials grant. The token
+// is cached and refreshed when it is about to expire, so a single
+// OAuth2ClientCredentials should be shared by all calls to an API.
+type OAuth2ClientCredentials struct {
+	// TokenURL is the URL of the token endpoint.
+	TokenURL string
+
+	// ClientID and ClientSecret are the credentials of the client, which are
+	// sent using HTTP basic authentication.
+	ClientID     string
+	ClientSecret string
+
+	// Scopes are the scopes requested, if any.
+	Scopes []string
+
+	// Client is used to request tokens. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Timeout limits each token request. If 0, DefaultTokenTimeout is used.
+	Timeout time.Duration
+
+	// RefreshMargin is how long before it expires that a token is refreshed.
+	// If 0, DefaultTokenRefreshMargin is used.
+	RefreshMargin time.Duration
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// tokenResponse is the response of an OAuth2 token endpoint.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Sign implements interface Signer by setting the Authorization header to the
+// current token, requesting a new one first if there is none or it expires
+// within RefreshMargin of now.
+func (a *OAuth2ClientCredentials) Sign(r *http.Request, now time.Time) error {
+	if r == nil {
+		return ErrNoRequest
+	}
+
+	token, err := a.Token(now)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Token returns the current token, requesting a new one if there is none or
+// it expires within RefreshMargin of now.
+func (a *OAuth2ClientCredentials) Token(now time.Time) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	margin := a.RefreshMargin
+	if margin == 0 {
+		margin = DefaultTokenRefreshMargin
+	}
+	if a.token != "" && (a.expires.IsZero() || a.expires.Sub(now) > margin) {
+		return a.token, nil
+	}
+
+	t, err := a.requestToken()
+	if err != nil {
+		return "", err
+	}
+
+	a.token = t.AccessToken
+	a.expires = time.Time{}
+	if t.ExpiresIn > 0 {
+		a.expires = now.Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	return a.token, nil
+}
+
+// Invalidate discards the current token, so that a new one is requested for
+// the next request (e.g. after the API rejects the token).
+func (a *OAuth2ClientCredentials) Invalidate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.token = ""
+}
+
+// requestToken requests a new token from the token endpoint.
+func (a *OAuth2ClientCredentials) requestToken() (*tokenResponse, error) {
+	timeout := a.Timeout
+	if timeout == 0 {
+		timeout = DefaultTokenTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(a.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.Scopes, " "))
+	}
+	req, err := http.NewRequest("POST", a.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(a.ClientID), url.QueryEscape(a.ClientSecret))
+
+	resp, err := ctxhttp.Do(ctx, a.Client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.WithFields(log.Fields{"Time": time.Now(), "statusCode": resp.StatusCode}).Debug("OAuth2: Token request failed")
+		return nil, ErrTokenRequestFailed
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	t := &tokenResponse{}
+	if err = json.Unmarshal(b, t); err != nil {
+		return nil, err
+	}
+	if t.AccessToken == "" {
+		return nil, ErrNoToken
+	}
+
+	return t, nil
+}
@@ -0,0 +1,113 @@
+package els
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Auth Test Suite", func() {
+
+	var (
+		now, _ = time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+		r      *http.Request
+	)
+
+	BeforeEach(func() {
+		var err error
+		r, err = http.NewRequest("GET", "https://example.com/things?a=1", nil)
+		Expect(err).To(BeNil())
+	})
+
+	It("signs with a bearer token", func() {
+		Expect((&BearerAuth{Token: "t"}).Sign(r, now)).To(Succeed())
+		Expect(r.Header.Get("Authorization")).To(Equal("Bearer t"))
+	})
+
+	It("signs with basic auth", func() {
+		Expect((&BasicAuth{Username: "u", Password: "p"}).Sign(r, now)).To(Succeed())
+		u, p, ok := r.BasicAuth()
+		Expect(ok).To(BeTrue())
+		Expect(u + ":" + p).To(Equal("u:p"))
+	})
+
+	It("signs with an API key", func() {
+		Expect((&APIKeyAuth{Key: "k"}).Sign(r, now)).To(Succeed())
+		Expect(r.Header.Get(DefaultAPIKeyHeader)).To(Equal("k"))
+
+		Expect((&APIKeyAuth{Key: "k", Header: "X-Key"}).Sign(r, now)).To(Succeed())
+		Expect(r.Header.Get("X-Key")).To(Equal("k"))
+
+		Expect((&APIKeyAuth{Key: "k", QueryParam: "key"}).Sign(r, now)).To(Succeed())
+		Expect(r.URL.RawQuery).To(Equal("a=1&key=k"))
+	})
+
+	Describe("OAuth2ClientCredentials", func() {
+		var (
+			server   *httptest.Server
+			requests int32
+			status   int
+			sut      *OAuth2ClientCredentials
+			creds    string
+			form     string
+		)
+
+		BeforeEach(func() {
+			atomic.StoreInt32(&requests, 0)
+			status = http.StatusOK
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&requests, 1)
+				id, secret, _ := r.BasicAuth()
+				creds = id + ":" + secret
+				r.ParseForm()
+				form = r.PostForm.Encode()
+				w.WriteHeader(status)
+				w.Write([]byte(`{"access_token":"token` + string('0'+n) + `","token_type":"bearer","expires_in":3600}`))
+			}))
+			sut = &OAuth2ClientCredentials{
+				TokenURL:     server.URL,
+				ClientID:     "client",
+				ClientSecret: "secret",
+				Scopes:       []string{"read", "write"},
+			}
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("requests a token and caches it until it is about to expire", func() {
+			Expect(sut.Sign(r, now)).To(Succeed())
+			Expect(r.Header.Get("Authorization")).To(Equal("Bearer token1"))
+			Expect(creds).To(Equal("client:secret"))
+			Expect(form).To(Equal("grant_type=client_credentials&scope=read+write"))
+
+			Expect(sut.Sign(r, now.Add(59*time.Minute))).To(Succeed())
+			Expect(r.Header.Get("Authorization")).To(Equal("Bearer token1"))
+
+			Expect(sut.Sign(r, now.Add(59*time.Minute+31*time.Second))).To(Succeed())
+			Expect(r.Header.Get("Authorization")).To(Equal("Bearer token2"))
+			Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+		})
+
+		It("requests a new token once invalidated", func() {
+			Expect(sut.Sign(r, now)).To(Succeed())
+			sut.Invalidate()
+			Expect(sut.Sign(r, now)).To(Succeed())
+			Expect(r.Header.Get("Authorization")).To(Equal("Bearer token2"))
+		})
+
+		Context("The token endpoint rejects the request", func() {
+			BeforeEach(func() {
+				status = http.StatusUnauthorized
+			})
+			It("returns ErrTokenRequestFailed", func() {
+				Expect(sut.Sign(r, now)).To(Equal(ErrTokenRequestFailed))
+			})
+		})
+	})
+})
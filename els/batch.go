@@ -0,0 +1,345 @@
+package els
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultBatchConcurrency is the number of calls a Batch makes at once if its
+// Concurrency is 0.
+const DefaultBatchConcurrency = 4
+
+// BatchFunc makes a call of a Batch which is not a simple request, e.g.
+// CreateAccessKey. It returns the value to report in BatchResult.Value.
+type BatchFunc func(ctx context.Context, a APICaller) (interface{}, error)
+
+// BatchItem is a call to be made by a Batch: either Request is sent with
+// APICaller.Do, or Func is called.
+type BatchItem struct {
+	// Request is the request to send. It is copied for each attempt, so is
+	// not modified; set GetBody if it has a body and may be retried.
+	Request *http.Request
+
+	// Signer signs Request, or is nil if it is not to be signed.
+	Signer Signer
+
+	// IsELSAPI is true if Request is a call to the ELS API.
+	IsELSAPI bool
+
+	// Func is called if Request is nil.
+	Func BatchFunc
+}
+
+// CreateAccessKeyItem returns a BatchItem which calls CreateAccessKey with the
+// given arguments, reporting the new *AccessKey as its value. Its retries have
+// the same idempotency key.
+func CreateAccessKeyItem(emailAddress string, password string, pwPrehashed bool, expiryDays uint) BatchItem {
+	key := NewIdempotencyKey()
+	return BatchItem{
+		Func: func(ctx context.Context, a APICaller) (interface{}, error) {
+			ctx = IdempotencyKeyContext(ctx, key)
+			k, _, err := a.CreateAccessKey(ctx, emailAddress, password, pwPrehashed, expiryDays)
+			if err != nil {
+				return nil, err
+			}
+			return k, nil
+		},
+	}
+}
+
+// BatchResult is the outcome of a BatchItem.
+type BatchResult struct {
+	// Response is the response to the final attempt at a Request item. Its
+	// body has been read into Body (so the connection is released) and may be
+	// read again.
+	Response *http.Response
+
+	// Body is the body of Response.
+	Body []byte
+
+	// Value is the value returned by a Func item.
+	Value interface{}
+
+	// Err is the error of the final attempt, or ErrUnexpectedStatusCode if the
+	// response has a status code of 400 or more. If the batch ended before the
+	// item was attempted, it is the error of the batch's context.
+	Err error
+
+	// Attempts is the number of attempts made.
+	Attempts int
+
+	// Duration is the time taken by all attempts, including delays.
+	Duration time.Duration
+}
+
+// BatchSummary summarises the results of a Batch.
+type BatchSummary struct {
+	Total     int
+	Succeeded int
+	Failed    int
+
+	// Attempts is the total number of attempts made, including retries.
+	Attempts int
+
+	// Duration is the time taken by the whole batch.
+	Duration time.Duration
+}
+
+// Batch makes many API calls with an APICaller, with bounded concurrency,
+// retries and an optional rate limit and deadline. Create one with NewBatch
+// and adjust its fields before calling Run.
+type Batch struct {
+	// Caller makes the calls.
+	Caller APICaller
+
+	// Concurrency is the maximum number of calls made at once. If 0,
+	// DefaultBatchConcurrency is used.
+	Concurrency int
+
+	// Retries is the number of times a failed call is retried, waiting
+	// RetryDelay multiplied by the number of attempts so far before each.
+	Retries    int
+	RetryDelay time.Duration
+
+	// ShouldRetry determines whether a call which returned the given status
+	// code (0 for a Func item or if there was no response) and error should be
+	// retried. By default, calls are retried after an error, a 429 or a 5xx
+	// response.
+	ShouldRetry func(statusCode int, err error) bool
+
+	// Timeout, if not 0, is the deadline for the whole batch: calls not made
+	// by then fail with context.DeadlineExceeded.
+	Timeout time.Duration
+
+	// ItemTimeout limits each attempt at a call.
+	ItemTimeout time.Duration
+
+	// RateLimit, if not 0, is the maximum number of attempts started per
+	// second.
+	RateLimit float64
+}
+
+// NewBatch returns a Batch which makes calls with a, one attempt per call and
+// the default per-call timeout.
+func NewBatch(a APICaller) *Batch {
+	return &Batch{
+		Caller:      a,
+		ShouldRetry: DefaultShouldRetry,
+		ItemTimeout: DefaultRequestTimeout,
+	}
+}
+
+// DefaultShouldRetry is the default value of Batch.ShouldRetry.
+func DefaultShouldRetry(statusCode int, err error) bool {
+	return err != nil || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// Run makes the calls described by items and returns their results, in the
+// same order, and a summary. Pass nil as ctx to use a background context.
+func (b *Batch) Run(ctx context.Context, items []BatchItem) ([]BatchResult, BatchSummary) {
+	start := time.Now()
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cancel := func() {}
+	if b.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+	}
+	defer cancel()
+
+	n := b.Concurrency
+	if n <= 0 {
+		n = DefaultBatchConcurrency
+	}
+
+	var lim *rateLimiter
+	if b.RateLimit > 0 {
+		lim = &rateLimiter{interval: time.Duration(float64(time.Second) / b.RateLimit)}
+	}
+
+	results := make([]BatchResult, len(items))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = b.run(ctx, lim, items[i])
+			}
+		}()
+	}
+
+feed:
+	for i := range items {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			for j := i; j < len(items); j++ {
+				results[j].Err = ctx.Err()
+			}
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	s := BatchSummary{Total: len(items), Duration: time.Since(start)}
+	for _, r := range results {
+		s.Attempts += r.Attempts
+		if r.Err == nil {
+			s.Succeeded++
+		} else {
+			s.Failed++
+		}
+	}
+
+	return results, s
+}
+
+// run makes the call described by item, retrying it as configured.
+func (b *Batch) run(ctx context.Context, lim *rateLimiter, item BatchItem) (r BatchResult) {
+	start := time.Now()
+	defer func() {
+		r.Duration = time.Since(start)
+	}()
+
+	shouldRetry := b.ShouldRetry
+	if shouldRetry == nil {
+		shouldRetry = DefaultShouldRetry
+	}
+
+	// Retries of the item are the same logical call.
+	key := NewIdempotencyKey()
+
+	for {
+		if r.Attempts > 0 {
+			if err := sleep(ctx, time.Duration(r.Attempts)*b.RetryDelay); err != nil {
+				return r
+			}
+		}
+		if err := lim.wait(ctx); err != nil {
+			if r.Attempts == 0 {
+				r.Err = err
+			}
+			return r
+		}
+
+		r = b.attempt(ctx, item, r.Attempts+1, key)
+
+		err := r.Err
+		statusCode := 0
+		if r.Response != nil {
+			statusCode = r.Response.StatusCode
+			if r.Err == nil && statusCode >= 400 {
+				r.Err = ErrUnexpectedStatusCode
+			}
+		}
+
+		if r.Err == nil || r.Attempts > b.Retries || ctx.Err() != nil || !item.canRetry() {
+			return r
+		}
+		if !shouldRetry(statusCode, err) {
+			return r
+		}
+	}
+}
+
+// attempt makes the given attempt (counting from 1) at the call described by
+// item, with the given idempotency key if the request needs one.
+func (b *Batch) attempt(ctx context.Context, item BatchItem, attempt int, key string) BatchResult {
+	r := BatchResult{Attempts: attempt}
+
+	actx := ctx
+	if item.Request != nil {
+		if route := RouteFromContext(item.Request.Context()); route != "" {
+			actx = WithRoute(actx, route)
+		}
+	}
+	cancel := func() {}
+	if b.ItemTimeout > 0 {
+		actx, cancel = context.WithTimeout(actx, b.ItemTimeout)
+	}
+	defer cancel()
+
+	if item.Request == nil {
+		r.Value, r.Err = item.Func(actx, b.Caller)
+		return r
+	}
+
+	req := copyRequest(actx, item.Request)
+	if attempt > 1 && item.Request.GetBody != nil {
+		if req.Body, r.Err = item.Request.GetBody(); r.Err != nil {
+			return r
+		}
+	}
+
+	opts := append(legacyOptions(item.Signer, item.IsELSAPI), withDefaultIdempotencyKey(key))
+	resp, err := b.Caller.DoWith(actx, req, opts...)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	// Read the body before the attempt's context is cancelled.
+	defer resp.Body.Close()
+	if r.Body, r.Err = ioutil.ReadAll(resp.Body); r.Err != nil {
+		return r
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(r.Body))
+	r.Response = resp
+
+	return r
+}
+
+// canRetry returns true if the call described by item can be made again.
+func (item BatchItem) canRetry() bool {
+	return item.Request == nil || canResend(item.Request)
+}
+
+// rateLimiter spaces the start of attempts at least interval apart. A nil
+// rateLimiter imposes no limit.
+type rateLimiter struct {
+	sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// wait waits until the next attempt may start, or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	l.Lock()
+	now := time.Now()
+	t := l.next
+	if t.Before(now) {
+		t = now
+	}
+	l.next = t.Add(l.interval)
+	l.Unlock()
+
+	return sleep(ctx, t.Sub(now))
+}
+
+// sleep waits for d, or until ctx is done in which case it returns ctx.Err().
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
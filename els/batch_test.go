@@ -0,0 +1,194 @@
+package els
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Batch Test Suite", func() {
+
+	var (
+		caller   *EDAPICaller
+		server   *httptest.Server
+		sut      *Batch
+		items    []BatchItem
+		results  []BatchResult
+		summary  BatchSummary
+		inFlight int32
+		maxSeen  int32
+
+		mu       sync.Mutex
+		attempts map[string]int
+
+		// newItem returns an item which GETs path.
+		newItem = func(path string) BatchItem {
+			r, err := http.NewRequest("GET", path, nil)
+			Expect(err).To(BeNil())
+			return BatchItem{Request: r, IsELSAPI: true}
+		}
+	)
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&inFlight, 0)
+		atomic.StoreInt32(&maxSeen, 0)
+		attempts = map[string]int{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				m := atomic.LoadInt32(&maxSeen)
+				if n <= m || atomic.CompareAndSwapInt32(&maxSeen, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			attempts[r.URL.Path]++
+			a := attempts[r.URL.Path]
+			mu.Unlock()
+
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/flaky") && a == 1:
+				w.WriteHeader(http.StatusServiceUnavailable)
+			case strings.HasSuffix(r.URL.Path, "/missing"):
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				w.Write([]byte(r.URL.Path))
+			}
+		}))
+		u, perr := url.Parse(server.URL)
+		Expect(perr).To(BeNil())
+
+		caller = NewEDAPICaller(&http.Client{}, datetime.NewNowTimeProvider(), time.Second, "")
+		caller.APIHandler.Scheme = u.Scheme
+		caller.APIHandler.Domain = u.Host
+
+		sut = NewBatch(caller)
+		items = nil
+		for _, p := range []string{"/a", "/b", "/c", "/d", "/e", "/f"} {
+			items = append(items, newItem(p))
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		results, summary = sut.Run(nil, items)
+	})
+
+	Context("All calls succeed", func() {
+		BeforeEach(func() {
+			sut.Concurrency = 2
+		})
+		It("returns the results in order, limiting concurrency", func() {
+			Expect(results).To(HaveLen(6))
+			for i, p := range []string{"/a", "/b", "/c", "/d", "/e", "/f"} {
+				Expect(results[i].Err).To(BeNil())
+				Expect(string(results[i].Body)).To(Equal("/1.0" + p))
+				Expect(results[i].Attempts).To(Equal(1))
+			}
+			Expect(atomic.LoadInt32(&maxSeen)).To(BeNumerically("<=", 2))
+			Expect(summary).To(matchIgnoringDuration(BatchSummary{Total: 6, Succeeded: 6, Attempts: 6}))
+		})
+		It("doesn't modify the requests", func() {
+			Expect(items[0].Request.URL.String()).To(Equal("/a"))
+		})
+	})
+
+	Context("Some calls fail", func() {
+		BeforeEach(func() {
+			items = []BatchItem{newItem("/flaky"), newItem("/missing"), newItem("/ok")}
+		})
+
+		It("reports per-item errors", func() {
+			Expect(results[0].Err).To(Equal(ErrUnexpectedStatusCode))
+			Expect(results[0].Response.StatusCode).To(Equal(http.StatusServiceUnavailable))
+			Expect(results[1].Err).To(Equal(ErrUnexpectedStatusCode))
+			Expect(results[2].Err).To(BeNil())
+			Expect(summary.Failed).To(Equal(2))
+			Expect(summary.Succeeded).To(Equal(1))
+		})
+
+		Context("Retries are enabled", func() {
+			BeforeEach(func() {
+				sut.Retries = 2
+				sut.RetryDelay = time.Millisecond
+			})
+			It("retries 5xx responses but not others", func() {
+				Expect(results[0].Err).To(BeNil())
+				Expect(results[0].Attempts).To(Equal(2))
+				Expect(results[1].Attempts).To(Equal(1))
+				Expect(summary.Attempts).To(Equal(4))
+			})
+		})
+	})
+
+	Context("The batch has a deadline", func() {
+		BeforeEach(func() {
+			sut.Concurrency = 1
+			sut.RateLimit = 20
+			sut.Timeout = 30 * time.Millisecond
+		})
+		It("fails the calls not made by the deadline", func() {
+			Expect(results[0].Err).To(BeNil())
+			Expect(results[5].Err).To(Equal(context.DeadlineExceeded))
+			Expect(summary.Failed).To(BeNumerically(">", 0))
+			Expect(summary.Succeeded + summary.Failed).To(Equal(6))
+		})
+	})
+
+	Context("Items have routes and a timeout", func() {
+		var observer *recordingObserver
+
+		BeforeEach(func() {
+			observer = &recordingObserver{name: "o", events: &[]string{}}
+			caller.AddObserver(observer)
+			sut.ItemTimeout = time.Second
+			items = items[:1]
+			items[0].Request = items[0].Request.WithContext(WithRoute(context.Background(), "/{letter}"))
+		})
+		It("reports the routes to observers", func() {
+			Expect(results[0].Err).To(BeNil())
+			Expect(observer.finished).To(HaveLen(1))
+			Expect(observer.finished[0].Route).To(Equal("/{letter}"))
+		})
+	})
+
+	Context("Items call functions", func() {
+		BeforeEach(func() {
+			boom := errors.New("boom")
+			items = []BatchItem{
+				{Func: func(ctx context.Context, a APICaller) (interface{}, error) { return 1, nil }},
+				{Func: func(ctx context.Context, a APICaller) (interface{}, error) { return nil, boom }},
+			}
+		})
+		It("reports their values and errors", func() {
+			Expect(results[0].Value).To(Equal(1))
+			Expect(results[1].Err).To(MatchError("boom"))
+		})
+	})
+})
+
+// matchIgnoringDuration matches a BatchSummary with the same counts as expected,
+// ignoring its duration.
+func matchIgnoringDuration(expected BatchSummary) OmegaMatcher {
+	return WithTransform(func(s BatchSummary) BatchSummary {
+		s.Duration = 0
+		return s
+	}, Equal(expected))
+}
@@ -0,0 +1,175 @@
+package els
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+// MaxBufferedBody is the largest request body which is held in memory while
+// it is digested for signing. A larger body which cannot be re-read by other
+// means is spooled to a temporary file instead.
+var MaxBufferedBody int64 = 1 << 20
+
+// ErrNoBodyDigest is returned when signing a request whose body is a
+// DigestedBody which does not supply the digest needed by the signing scheme.
+var ErrNoBodyDigest = errors.New("No Body Digest")
+
+// BodyDigest holds the hex-encoded digests and the size of a request body.
+type BodyDigest struct {
+	// MD5 is the digest used by SchemeV1.
+	MD5 string
+
+	// SHA256 is the digest used by SchemeV2.
+	SHA256 string
+
+	// Size is the length of the body in bytes.
+	Size int64
+}
+
+// DigestedBody is implemented by request bodies which already know their
+// digest, so that a signer need not read them.
+type DigestedBody interface {
+	io.ReadCloser
+	BodyDigest() BodyDigest
+}
+
+// NewDigestedBody returns a request body which reads from rc and reports the
+// precomputed digest d. Use it when the digest of a large upload is already
+// known (e.g. it was computed as the file was written) to avoid reading the
+// body twice. Only the digest required by the signing scheme need be set, but
+// Size must be. Remember to set the ContentLength of the request too.
+func NewDigestedBody(rc io.ReadCloser, d BodyDigest) io.ReadCloser {
+	return &digestedBody{ReadCloser: rc, digest: d}
+}
+
+// digestedBody implements interface DigestedBody.
+type digestedBody struct {
+	io.ReadCloser
+	digest BodyDigest
+}
+
+// BodyDigest implements interface DigestedBody.
+func (b *digestedBody) BodyDigest() BodyDigest {
+	return b.digest
+}
+
+// digestBody returns the digest of the body of request r without holding
+// more than MaxBufferedBody bytes of it in memory, leaving the body ready to
+// be sent. In order of preference, the digest is taken from a DigestedBody,
+// computed from a fresh copy of the body obtained with r.GetBody, or computed
+// by reading a seekable body and seeking back. Otherwise (including for bodies
+// such as pipes which implement io.Seeker but can't seek) the body is read and
+// replaced with a buffered (or, if large, spooled) copy.
+func digestBody(r *http.Request) (BodyDigest, error) {
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return hashReader(bytes.NewReader(nil), ioutil.Discard)
+	}
+
+	if db, ok := r.Body.(DigestedBody); ok {
+		return db.BodyDigest(), nil
+	}
+
+	if r.GetBody != nil {
+		rc, err := r.GetBody()
+		if err != nil {
+			return BodyDigest{}, err
+		}
+		defer rc.Close()
+		return hashReader(rc, ioutil.Discard)
+	}
+
+	if s, ok := r.Body.(io.ReadSeeker); ok {
+		start, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return spoolBody(r)
+		}
+		d, err := hashReader(s, ioutil.Discard)
+		if err != nil {
+			return BodyDigest{}, err
+		}
+		_, err = s.Seek(start, io.SeekStart)
+		return d, err
+	}
+
+	return spoolBody(r)
+}
+
+// spoolBody digests the body of request r as it copies it into memory or, if
+// larger than MaxBufferedBody, into a temporary file, and replaces the body
+// with the copy.
+func spoolBody(r *http.Request) (BodyDigest, error) {
+
+	defer r.Body.Close()
+
+	buf := &bytes.Buffer{}
+	d, err := hashReader(io.LimitReader(r.Body, MaxBufferedBody+1), buf)
+	if err != nil {
+		return BodyDigest{}, err
+	}
+
+	if d.Size <= MaxBufferedBody {
+		b := buf.Bytes()
+		r.Body = ioutil.NopCloser(bytes.NewReader(b))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		}
+		return d, nil
+	}
+
+	f, err := ioutil.TempFile("", "els-body")
+	if err != nil {
+		return BodyDigest{}, err
+	}
+
+	d, err = hashReader(io.MultiReader(buf, r.Body), f)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return BodyDigest{}, err
+	}
+
+	r.Body = &spooledBody{f}
+	return d, nil
+}
+
+// spooledBody is a request body held in a temporary file, which is removed
+// when the body is closed.
+type spooledBody struct {
+	*os.File
+}
+
+// Close closes and removes the temporary file.
+func (b *spooledBody) Close() error {
+	err := b.File.Close()
+	os.Remove(b.File.Name())
+	return err
+}
+
+// hashReader computes the digest of everything read from rd, copying it to w.
+func hashReader(rd io.Reader, w io.Writer) (BodyDigest, error) {
+	m, s := md5.New(), sha256.New()
+
+	n, err := io.Copy(io.MultiWriter(m, s, w), rd)
+	if err != nil {
+		return BodyDigest{}, err
+	}
+
+	return BodyDigest{MD5: hexSum(m), SHA256: hexSum(s), Size: n}, nil
+}
+
+// hexSum returns the hex-encoded sum of h.
+func hexSum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
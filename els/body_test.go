@@ -0,0 +1,206 @@
+package els
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// readOnce is a body which can be read only once and records whether it was
+// closed.
+type readOnce struct {
+	io.Reader
+	closed bool
+}
+
+func (b *readOnce) Close() error {
+	b.closed = true
+	return nil
+}
+
+var _ = Describe("Body Test Suite", func() {
+
+	var (
+		content  []byte
+		expected BodyDigest
+		r        *http.Request
+		d        BodyDigest
+		err      error
+		maxBuf   int64
+
+		digestOf = func(b []byte) BodyDigest {
+			return BodyDigest{
+				MD5:    fmt.Sprintf("%x", md5.Sum(b)),
+				SHA256: fmt.Sprintf("%x", sha256.Sum256(b)),
+				Size:   int64(len(b)),
+			}
+		}
+
+		readBody = func() []byte {
+			b, rerr := ioutil.ReadAll(r.Body)
+			Expect(rerr).To(BeNil())
+			Expect(r.Body.Close()).To(BeNil())
+			return b
+		}
+	)
+
+	BeforeEach(func() {
+		maxBuf = MaxBufferedBody
+		content = bytes.Repeat([]byte("0123456789"), 100)
+		expected = digestOf(content)
+	})
+
+	AfterEach(func() {
+		MaxBufferedBody = maxBuf
+	})
+
+	Describe("digestBody", func() {
+		JustBeforeEach(func() {
+			d, err = digestBody(r)
+		})
+
+		Context("The request has no body", func() {
+			BeforeEach(func() {
+				r, err = http.NewRequest("GET", "/1.0/route", nil)
+				Expect(err).To(BeNil())
+			})
+			It("returns the digest of an empty body", func() {
+				Expect(err).To(BeNil())
+				Expect(d).To(Equal(digestOf(nil)))
+			})
+		})
+
+		Context("The request body can be re-obtained with GetBody", func() {
+			BeforeEach(func() {
+				r, err = http.NewRequest("POST", "/1.0/route", bytes.NewReader(content))
+				Expect(err).To(BeNil())
+			})
+			It("digests a copy and leaves the body unread", func() {
+				Expect(err).To(BeNil())
+				Expect(d).To(Equal(expected))
+				Expect(readBody()).To(Equal(content))
+			})
+		})
+
+		Context("The request body is seekable", func() {
+			var f *os.File
+
+			BeforeEach(func() {
+				f, err = ioutil.TempFile("", "els-body-test")
+				Expect(err).To(BeNil())
+				_, err = f.Write(content)
+				Expect(err).To(BeNil())
+				_, err = f.Seek(0, io.SeekStart)
+				Expect(err).To(BeNil())
+				r, err = http.NewRequest("POST", "/1.0/route", f)
+				Expect(err).To(BeNil())
+				Expect(r.GetBody).To(BeNil())
+			})
+			AfterEach(func() {
+				os.Remove(f.Name())
+			})
+			It("digests the body and seeks back to the start", func() {
+				Expect(err).To(BeNil())
+				Expect(d).To(Equal(expected))
+				Expect(readBody()).To(Equal(content))
+			})
+		})
+
+		Context("The request body is a pipe, which can't seek", func() {
+			BeforeEach(func() {
+				pr, pw, perr := os.Pipe()
+				Expect(perr).To(BeNil())
+				go func() {
+					pw.Write(content)
+					pw.Close()
+				}()
+				r, err = http.NewRequest("POST", "/1.0/route", pr)
+				Expect(err).To(BeNil())
+			})
+			It("buffers the body", func() {
+				Expect(err).To(BeNil())
+				Expect(d).To(Equal(expected))
+				Expect(r.GetBody).NotTo(BeNil())
+				Expect(readBody()).To(Equal(content))
+			})
+		})
+
+		Context("The request body is a DigestedBody", func() {
+			var b *readOnce
+
+			BeforeEach(func() {
+				b = &readOnce{Reader: bytes.NewReader(content)}
+				r, err = http.NewRequest("POST", "/1.0/route", NewDigestedBody(b, expected))
+				Expect(err).To(BeNil())
+			})
+			It("uses the precomputed digest without reading the body", func() {
+				Expect(err).To(BeNil())
+				Expect(d).To(Equal(expected))
+				Expect(readBody()).To(Equal(content))
+			})
+		})
+
+		Context("The request body can only be read once", func() {
+			var b *readOnce
+
+			BeforeEach(func() {
+				b = &readOnce{Reader: bytes.NewReader(content)}
+				r, err = http.NewRequest("POST", "/1.0/route", b)
+				Expect(err).To(BeNil())
+			})
+
+			Context("The body is small", func() {
+				It("buffers the body in memory", func() {
+					Expect(err).To(BeNil())
+					Expect(d).To(Equal(expected))
+					Expect(b.closed).To(BeTrue())
+					Expect(r.GetBody).NotTo(BeNil())
+					Expect(readBody()).To(Equal(content))
+				})
+			})
+
+			Context("The body is larger than MaxBufferedBody", func() {
+				BeforeEach(func() {
+					MaxBufferedBody = 64
+				})
+				It("spools the body to a temporary file which is removed on close", func() {
+					Expect(err).To(BeNil())
+					Expect(d).To(Equal(expected))
+					sb, ok := r.Body.(*spooledBody)
+					Expect(ok).To(BeTrue())
+					Expect(readBody()).To(Equal(content))
+					_, serr := os.Stat(sb.Name())
+					Expect(os.IsNotExist(serr)).To(BeTrue())
+				})
+			})
+		})
+	})
+
+	Describe("Signing", func() {
+		var (
+			k = &AccessKey{ID: "id", SecretAccessKey: "sac"}
+			s *APISigner
+		)
+
+		Context("A DigestedBody lacks the digest required by the scheme", func() {
+			BeforeEach(func() {
+				s, err = NewAPISigner(k)
+				Expect(err).To(BeNil())
+				b := NewDigestedBody(ioutil.NopCloser(bytes.NewReader(content)), BodyDigest{SHA256: expected.SHA256, Size: expected.Size})
+				r, err = http.NewRequest("POST", "/1.0/route", b)
+				Expect(err).To(BeNil())
+			})
+			It("returns ErrNoBodyDigest", func() {
+				Expect(s.Sign(r, k.ExpiryDate)).To(Equal(ErrNoBodyDigest))
+			})
+		})
+	})
+})
@@ -0,0 +1,250 @@
+package els
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// MaxCachedBody is the size of the largest response body which will be
+// cached.
+var MaxCachedBody int64 = 1 << 20
+
+// Cache stores the responses of GET requests made by an EDAPICaller (see
+// EDAPICaller.SetCache). Entries must not be modified once stored; an
+// implementation must be safe for concurrent use.
+type Cache interface {
+	// Get returns the response stored under key, or nil if there is none.
+	Get(key string) *CachedResponse
+
+	// Set stores the response under key, replacing any existing entry.
+	Set(key string, cr *CachedResponse)
+
+	// Delete removes any response stored under key.
+	Delete(key string)
+}
+
+// CachedResponse is a response stored in a Cache.
+type CachedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+
+	// Stored is when the response was stored or last revalidated.
+	Stored time.Time `json:"stored"`
+
+	// FreshUntil is when the response becomes stale and must be revalidated
+	// with the server before being used again.
+	FreshUntil time.Time `json:"freshUntil"`
+}
+
+// Fresh returns true if the response can be used at time now without being
+// revalidated.
+func (cr *CachedResponse) Fresh(now time.Time) bool {
+	return now.Before(cr.FreshUntil)
+}
+
+// Response returns a new http.Response for request r with a copy of the
+// contents of the cached response, which can be modified without affecting
+// it.
+func (cr *CachedResponse) Response(r *http.Request) *http.Response {
+	h := cr.Header.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(cr.StatusCode) + " " + http.StatusText(cr.StatusCode),
+		StatusCode:    cr.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          ioutil.NopCloser(bytes.NewReader(append([]byte(nil), cr.Body...))),
+		ContentLength: int64(len(cr.Body)),
+		Request:       r,
+	}
+}
+
+// SetCache enables caching of the responses to GET requests in c, or disables
+// it if c is nil. Responses are cached according to their Cache-Control (or
+// Expires) header, and revalidated with If-None-Match and If-Modified-Since
+// once stale. They are keyed by URL and the ID of the signing access key, so
+// requests signed by signers which don't implement KeyInfo are not cached.
+// Requests which carry their own conditional headers, or whose Cache-Control
+// header contains no-store, bypass the cache; no-cache forces revalidation.
+// Responses served from the cache are passed to the StageAfterResponse
+// interceptors as if they had been received.
+func (a *EDAPICaller) SetCache(c Cache) {
+	a.Lock()
+	defer a.Unlock()
+	a.cache = c
+}
+
+// getCache returns the cache set with SetCache.
+func (a *EDAPICaller) getCache() Cache {
+	a.RLock()
+	defer a.RUnlock()
+	return a.cache
+}
+
+// cacheKey returns the key under which the response to request r, signed by s,
+// is cached, and false if the response should not be cached.
+func cacheKey(r *http.Request, s Signer) (string, bool) {
+	if r.Method != "GET" || r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+		return "", false
+	}
+	if _, ok := cacheControl(r.Header)["no-store"]; ok {
+		return "", false
+	}
+
+	id := ""
+	if s != nil {
+		k, ok := s.(KeyInfo)
+		if !ok {
+			return "", false
+		}
+		id = string(k.AccessKeyID())
+	}
+
+	return id + " " + r.URL.String(), true
+}
+
+// fromCache returns the cached response to request r, if it is fresh at time
+// now.
+func fromCache(r *http.Request, cr *CachedResponse, now time.Time) *http.Response {
+	if _, ok := cacheControl(r.Header)["no-cache"]; !ok && cr.Fresh(now) {
+		return cr.Response(r)
+	}
+	return nil
+}
+
+// revalidation returns a copy of request r, with context ctx, with the headers
+// needed to revalidate the stale cached response cr.
+func revalidation(ctx context.Context, r *http.Request, cr *CachedResponse) *http.Request {
+	c := copyRequest(ctx, r)
+	if etag := cr.Header.Get("ETag"); etag != "" {
+		c.Header.Set("If-None-Match", etag)
+	}
+	if lm := cr.Header.Get("Last-Modified"); lm != "" {
+		c.Header.Set("If-Modified-Since", lm)
+	}
+	return c
+}
+
+// updateCache updates the cache with resp, the response to the request cached
+// under key at time now, whose previously cached response (if any) was cr. It
+// returns the response to return to the caller.
+func updateCache(c Cache, key string, cr *CachedResponse, resp *http.Response, now time.Time) *http.Response {
+
+	if resp.StatusCode == http.StatusNotModified && cr != nil {
+		resp.Body.Close()
+		u := *cr
+		u.Header = cr.Header.Clone()
+		for _, k := range []string{"Cache-Control", "Date", "ETag", "Expires", "Last-Modified"} {
+			if v, ok := resp.Header[k]; ok {
+				u.Header[k] = append([]string(nil), v...)
+			}
+		}
+		u.Stored = now
+		u.FreshUntil = freshUntil(u.Header, now)
+		c.Set(key, &u)
+		return u.Response(resp.Request)
+	}
+
+	if resp.StatusCode != http.StatusOK || !storable(resp.Header, now) {
+		if cr != nil {
+			c.Delete(key)
+		}
+		return resp
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxCachedBody+1))
+	if err != nil || int64(len(body)) > MaxCachedBody {
+		// Return what was read and whatever remains.
+		log.WithFields(log.Fields{"Time": time.Now(), "err": err}).Debug("ApiCaller: Not caching response")
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	// Store copies, as the caller may modify the response.
+	c.Set(key, &CachedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       append([]byte(nil), body...),
+		Stored:     now,
+		FreshUntil: freshUntil(resp.Header, now),
+	})
+
+	return resp
+}
+
+// storable returns true if a response with header h received at time now may
+// be cached: either it is fresh for some time, or it can be revalidated.
+func storable(h http.Header, now time.Time) bool {
+	if _, ok := cacheControl(h)["no-store"]; ok {
+		return false
+	}
+	return freshUntil(h, now).After(now) || h.Get("ETag") != "" || h.Get("Last-Modified") != ""
+}
+
+// freshUntil returns when a response with header h, received at time now,
+// becomes stale.
+func freshUntil(h http.Header, now time.Time) time.Time {
+	cc := cacheControl(h)
+	if _, ok := cc["no-cache"]; ok {
+		return now
+	}
+
+	if v, ok := cc["max-age"]; ok {
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return now.Add(time.Duration(secs) * time.Second)
+		}
+		return now
+	}
+
+	if e := h.Get("Expires"); e != "" {
+		expires, err := http.ParseTime(e)
+		if err != nil {
+			return now
+		}
+		// Use the lifetime of the response according to the server's clock.
+		if date, err := http.ParseTime(h.Get("Date")); err == nil {
+			return now.Add(expires.Sub(date))
+		}
+		return expires
+	}
+
+	return now
+}
+
+// cacheControl returns the directives of the Cache-Control header in h, with
+// their values (if any).
+func cacheControl(h http.Header) map[string]string {
+	cc := map[string]string{}
+	for _, v := range h["Cache-Control"] {
+		for _, d := range strings.Split(v, ",") {
+			d = strings.TrimSpace(d)
+			if d == "" {
+				continue
+			}
+			k, val := d, ""
+			if i := strings.Index(d, "="); i >= 0 {
+				k, val = d[:i], strings.Trim(d[i+1:], `"`)
+			}
+			cc[strings.ToLower(k)] = val
+		}
+	}
+	return cc
+}
@@ -0,0 +1,168 @@
+package els
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultMemoryCacheSize is the number of responses held by a MemoryCache
+// created with a size of 0.
+const DefaultMemoryCacheSize = 256
+
+// MemoryCache implements interface Cache in memory, discarding the least
+// recently used response when full.
+type MemoryCache struct {
+	sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+// memoryEntry is an element of MemoryCache.lru.
+type memoryEntry struct {
+	key string
+	cr  *CachedResponse
+}
+
+// NewMemoryCache returns a MemoryCache holding up to size responses. Pass 0 for
+// DefaultMemoryCacheSize.
+func NewMemoryCache(size int) *MemoryCache {
+	if size <= 0 {
+		size = DefaultMemoryCacheSize
+	}
+	return &MemoryCache{
+		size:    size,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// Get implements interface Cache.
+func (m *MemoryCache) Get(key string) *CachedResponse {
+	m.Lock()
+	defer m.Unlock()
+	e, ok := m.entries[key]
+	if !ok {
+		return nil
+	}
+	m.lru.MoveToFront(e)
+	return e.Value.(*memoryEntry).cr
+}
+
+// Set implements interface Cache.
+func (m *MemoryCache) Set(key string, cr *CachedResponse) {
+	m.Lock()
+	defer m.Unlock()
+	if e, ok := m.entries[key]; ok {
+		e.Value.(*memoryEntry).cr = cr
+		m.lru.MoveToFront(e)
+		return
+	}
+
+	m.entries[key] = m.lru.PushFront(&memoryEntry{key: key, cr: cr})
+	for m.lru.Len() > m.size {
+		e := m.lru.Back()
+		m.lru.Remove(e)
+		delete(m.entries, e.Value.(*memoryEntry).key)
+	}
+}
+
+// Delete implements interface Cache.
+func (m *MemoryCache) Delete(key string) {
+	m.Lock()
+	defer m.Unlock()
+	if e, ok := m.entries[key]; ok {
+		m.lru.Remove(e)
+		delete(m.entries, key)
+	}
+}
+
+// Len returns the number of responses in the cache.
+func (m *MemoryCache) Len() int {
+	m.Lock()
+	defer m.Unlock()
+	return m.lru.Len()
+}
+
+// DiskCache implements interface Cache by storing each response as a file in
+// a directory, so that it survives restarts. Files are readable only by their
+// owner, since responses may contain licence details.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a DiskCache which stores responses in directory dir,
+// creating it if necessary.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+// path returns the path of the file in which the response with the given key
+// is stored.
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get implements interface Cache. Unreadable entries are treated as missing.
+func (d *DiskCache) Get(key string) *CachedResponse {
+	b, err := ioutil.ReadFile(d.path(key))
+	if err != nil {
+		return nil
+	}
+	cr := &CachedResponse{}
+	if err := json.Unmarshal(b, cr); err != nil {
+		log.WithFields(log.Fields{"Time": time.Now(), "err": err}).Debug("DiskCache: Invalid entry")
+		return nil
+	}
+	return cr
+}
+
+// Set implements interface Cache. The file is replaced atomically so that
+// concurrent readers never see a partial entry.
+func (d *DiskCache) Set(key string, cr *CachedResponse) {
+	b, err := json.Marshal(cr)
+	if err == nil {
+		err = writeFileAtomic(d.path(key), b)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{"Time": time.Now(), "err": err}).Debug("DiskCache: Failed to store entry")
+	}
+}
+
+// Delete implements interface Cache.
+func (d *DiskCache) Delete(key string) {
+	os.Remove(d.path(key))
+}
+
+// writeFileAtomic writes b to a temporary file and renames it to path.
+func writeFileAtomic(path string, b []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
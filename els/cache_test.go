@@ -0,0 +1,241 @@
+package els
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cache Test Suite", func() {
+
+	var (
+		now, _   = time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+		tp       *datetime.NowTimeProvider
+		sut      *EDAPICaller
+		server   *httptest.Server
+		signer   *APISigner
+		header   http.Header
+		requests []*http.Request
+		cache    Cache
+
+		// get makes a GET request and returns the status code and body.
+		get = func(s Signer) (int, string) {
+			rep, err := sut.Get(nil, "/vendors/v1", s, true)
+			Expect(err).To(BeNil())
+			defer rep.Body.Close()
+			b, err := ioutil.ReadAll(rep.Body)
+			Expect(err).To(BeNil())
+			return rep.StatusCode, string(b)
+		}
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		header = http.Header{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			if inm := r.Header.Get("If-None-Match"); inm != "" && inm == header.Get("ETag") {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte("response " + strconv.Itoa(len(requests))))
+		}))
+		u, perr := url.Parse(server.URL)
+		Expect(perr).To(BeNil())
+
+		tp = datetime.NewNowTimeProvider()
+		tp.SetNow(now)
+		sut = NewEDAPICaller(&http.Client{}, tp, time.Second, "")
+		sut.APIHandler.Scheme = u.Scheme
+		sut.APIHandler.Domain = u.Host
+
+		var err error
+		signer, err = NewAPISigner(&AccessKey{ID: "id1", SecretAccessKey: "secret"})
+		Expect(err).To(BeNil())
+
+		cache = NewMemoryCache(0)
+	})
+
+	JustBeforeEach(func() {
+		sut.SetCache(cache)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("The response has a max-age", func() {
+		BeforeEach(func() {
+			header.Set("Cache-Control", "private, max-age=60")
+		})
+
+		It("serves repeated requests from the cache while fresh", func() {
+			code, body := get(signer)
+			Expect(code).To(Equal(200))
+			_, body = get(signer)
+			Expect(body).To(Equal("response 1"))
+			Expect(requests).To(HaveLen(1))
+
+			tp.SetNow(now.Add(time.Minute))
+			_, body = get(signer)
+			Expect(body).To(Equal("response 2"))
+		})
+
+		It("keys responses by access key", func() {
+			get(signer)
+			other, err := NewAPISigner(&AccessKey{ID: "id2", SecretAccessKey: "secret"})
+			Expect(err).To(BeNil())
+			_, body := get(other)
+			Expect(body).To(Equal("response 2"))
+		})
+
+		It("returns copies of the cached response, which can be modified", func() {
+			rep, err := sut.Get(nil, "/vendors/v1", signer, true)
+			Expect(err).To(BeNil())
+			rep.Header.Set("Cache-Control", "modified")
+			rep.Body.Close()
+
+			for i := 0; i < 2; i++ {
+				rep, err = sut.Get(nil, "/vendors/v1", signer, true)
+				Expect(err).To(BeNil())
+				Expect(rep.Header.Get("Cache-Control")).To(Equal("private, max-age=60"))
+				rep.Header.Set("Cache-Control", "modified")
+				rep.Body.Close()
+			}
+			Expect(requests).To(HaveLen(1))
+		})
+
+		It("runs the after-response interceptors on responses from the cache", func() {
+			var seen []int
+			sut.Use(StageAfterResponse, InterceptorFunc(func(ctx context.Context, e *Exchange) error {
+				seen = append(seen, e.Response.StatusCode)
+				return nil
+			}))
+			get(signer)
+			get(signer)
+			Expect(requests).To(HaveLen(1))
+			Expect(seen).To(Equal([]int{200, 200}))
+		})
+
+		It("doesn't cache requests signed by signers without KeyInfo", func() {
+			get(&DummySigner{})
+			get(&DummySigner{})
+			Expect(requests).To(HaveLen(2))
+		})
+	})
+
+	Context("The response has an ETag", func() {
+		BeforeEach(func() {
+			header.Set("ETag", `"v1"`)
+			header.Set("Cache-Control", "no-cache")
+		})
+
+		It("revalidates it and serves the cached body when not modified", func() {
+			get(signer)
+			code, body := get(signer)
+			Expect(code).To(Equal(200))
+			Expect(body).To(Equal("response 1"))
+			Expect(requests).To(HaveLen(2))
+			Expect(requests[1].Header.Get("If-None-Match")).To(Equal(`"v1"`))
+		})
+
+		It("revalidates it without modifying the caller's request", func() {
+			get(signer)
+			r, err := http.NewRequest("GET", "/vendors/v1", nil)
+			Expect(err).To(BeNil())
+			rep, err := sut.Do(nil, r, signer, true)
+			Expect(err).To(BeNil())
+			rep.Body.Close()
+			Expect(requests[1].Header.Get("If-None-Match")).To(Equal(`"v1"`))
+			Expect(r.Header.Get("If-None-Match")).To(BeEmpty())
+		})
+
+		It("replaces it when modified", func() {
+			get(signer)
+			header.Set("ETag", `"v2"`)
+			_, body := get(signer)
+			Expect(body).To(Equal("response 2"))
+			_, body = get(signer)
+			Expect(body).To(Equal("response 2"))
+			Expect(requests[2].Header.Get("If-None-Match")).To(Equal(`"v2"`))
+		})
+	})
+
+	Context("The response has no-store", func() {
+		BeforeEach(func() {
+			header.Set("Cache-Control", "no-store, max-age=60")
+		})
+		It("isn't cached", func() {
+			get(signer)
+			get(signer)
+			Expect(requests).To(HaveLen(2))
+		})
+	})
+
+	Context("The response expires", func() {
+		BeforeEach(func() {
+			header.Set("Date", "Wed, 21 Oct 2015 07:28:00 GMT")
+			header.Set("Expires", "Wed, 21 Oct 2015 07:29:00 GMT")
+		})
+		It("is fresh for its lifetime according to the server clock", func() {
+			get(signer)
+			tp.SetNow(now.Add(59 * time.Second))
+			get(signer)
+			Expect(requests).To(HaveLen(1))
+		})
+	})
+
+	Context("The cache is on disk", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "els-cache")
+			Expect(err).To(BeNil())
+			cache, err = NewDiskCache(dir)
+			Expect(err).To(BeNil())
+			header.Set("Cache-Control", "max-age=60")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("serves responses cached by another caller", func() {
+			get(signer)
+			sut.SetCache(nil)
+			d, err := NewDiskCache(dir)
+			Expect(err).To(BeNil())
+			sut.SetCache(d)
+			_, body := get(signer)
+			Expect(body).To(Equal("response 1"))
+			Expect(requests).To(HaveLen(1))
+		})
+	})
+
+	Describe("MemoryCache", func() {
+		It("discards the least recently used response when full", func() {
+			m := NewMemoryCache(2)
+			m.Set("a", &CachedResponse{})
+			m.Set("b", &CachedResponse{})
+			Expect(m.Get("a")).NotTo(BeNil())
+			m.Set("c", &CachedResponse{})
+			Expect(m.Len()).To(Equal(2))
+			Expect(m.Get("b")).To(BeNil())
+			Expect(m.Get("a")).NotTo(BeNil())
+		})
+	})
+})
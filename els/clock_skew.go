@@ -0,0 +1,89 @@
+package els
+
+import (
+	"net/http"
+	"time"
+)
+
+// minClockSkewRetry is the smallest change in the estimated clock offset that
+// is taken to explain the rejection of a signature.
+const minClockSkewRetry = time.Minute
+
+// ClockOffset returns the latest estimate of how far the ELS clock is ahead of
+// the local clock (negative if it is behind), derived from the Date header of
+// ELS responses. The estimate has a resolution of about a second and is zero
+// until the first response is received.
+func (a *EDAPICaller) ClockOffset() time.Duration {
+	a.RLock()
+	defer a.RUnlock()
+	return a.clockOffset
+}
+
+// SetClockCorrection determines whether the estimated clock offset is added to
+// the local time used to sign requests, and whether requests rejected because
+// of the time they were signed are re-signed and sent again. It is off by
+// default: enable it if the local clock cannot be relied on.
+func (a *EDAPICaller) SetClockCorrection(on bool) {
+	a.Lock()
+	defer a.Unlock()
+	a.correctClock = on
+}
+
+// signingOffset returns the offset to apply to the time used to sign a
+// request.
+func (a *EDAPICaller) signingOffset() time.Duration {
+	a.RLock()
+	defer a.RUnlock()
+	if !a.correctClock {
+		return 0
+	}
+	return a.clockOffset
+}
+
+// recordServerTime updates the clock offset estimate from the Date header of
+// resp, assuming the server generated it halfway between the request being
+// sent and the response being received.
+func (a *EDAPICaller) recordServerTime(resp *http.Response, sent time.Time, received time.Time) {
+	d := resp.Header.Get("Date")
+	if d == "" {
+		return
+	}
+
+	serverTime, err := http.ParseTime(d)
+	if err != nil {
+		return
+	}
+
+	// Date has a resolution of a second, so on average the server time is
+	// half a second later than reported.
+	serverTime = serverTime.Add(500 * time.Millisecond)
+	local := sent.Add(received.Sub(sent) / 2)
+
+	a.Lock()
+	a.clockOffset = serverTime.Sub(local).Round(time.Second)
+	a.Unlock()
+}
+
+// isClockRejection returns true if clock correction is enabled and resp
+// rejects a signature made with the given offset, which now appears to have
+// been wrong.
+func (a *EDAPICaller) isClockRejection(resp *http.Response, signedOffset time.Duration) bool {
+	if resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+
+	a.RLock()
+	defer a.RUnlock()
+
+	if !a.correctClock {
+		return false
+	}
+
+	d := a.clockOffset - signedOffset
+	return d >= minClockSkewRetry || d <= -minClockSkewRetry
+}
+
+// canResend returns true if the body of r, if any, can be obtained again.
+func canResend(r *http.Request) bool {
+	return r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+}
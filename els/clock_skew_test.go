@@ -0,0 +1,156 @@
+package els
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Clock Skew Test Suite", func() {
+
+	var (
+		sut          *EDAPICaller
+		tp           = datetime.NewNowTimeProvider()
+		server       *httptest.Server
+		dummySigner  *DummySigner
+		skew         time.Duration
+		mu           sync.Mutex
+		numRequests  int
+		bodies       []string
+		dates        [][]string
+		rep          *http.Response
+		err          error
+		req          *http.Request
+		reqContent   = `{"some":"req"}`
+		nearlyEqual  = func(a, b time.Duration) bool { d := a - b; return d < 2*time.Second && d > -2*time.Second }
+		requestCount = func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return numRequests
+		}
+	)
+
+	BeforeEach(func() {
+		numRequests = 0
+		bodies = nil
+		dates = nil
+		skew = -10 * time.Minute
+		tp.SetNow(time.Now().Add(skew))
+		dummySigner = &DummySigner{}
+
+		// The server rejects any request whose signing time differs from its
+		// own by more than two minutes.
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			mu.Lock()
+			numRequests++
+			bodies = append(bodies, string(b))
+			dates = append(dates, r.Header["X-Els-Date"])
+			mu.Unlock()
+
+			t, perr := time.Parse(time.RFC3339, r.Header.Get("X-Els-Date"))
+			if perr != nil || t.Sub(time.Now()) > 2*time.Minute || time.Now().Sub(t) > 2*time.Minute {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		u, perr := url.Parse(server.URL)
+		Expect(perr).To(BeNil())
+
+		sut = NewEDAPICaller(&http.Client{}, tp, time.Second, "")
+		sut.APIHandler.Scheme = u.Scheme
+		sut.APIHandler.Domain = u.Host
+
+		req, err = http.NewRequest("POST", "/path/to/route", bytes.NewBufferString(reqContent))
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		if rep != nil {
+			rep.Body.Close()
+		}
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		rep, err = sut.Do(nil, req, dummySigner, true)
+	})
+
+	Context("Clock correction is disabled", func() {
+		It("estimates the offset but does not resend the request", func() {
+			Expect(err).To(BeNil())
+			Expect(rep.StatusCode).To(Equal(http.StatusUnauthorized))
+			Expect(requestCount()).To(Equal(1))
+			Expect(nearlyEqual(sut.ClockOffset(), -skew)).To(BeTrue())
+		})
+	})
+
+	Context("Clock correction is enabled", func() {
+		BeforeEach(func() {
+			sut.SetClockCorrection(true)
+		})
+
+		It("re-signs the request with the corrected time and resends it once", func() {
+			Expect(err).To(BeNil())
+			Expect(rep.StatusCode).To(Equal(http.StatusOK))
+			Expect(requestCount()).To(Equal(2))
+			Expect(bodies).To(Equal([]string{reqContent, reqContent}))
+			Expect(dates).To(HaveLen(2))
+			Expect(dates[1]).To(HaveLen(1))
+			Expect(req.Header.Get("X-Els-Date")).To(BeEmpty())
+			Expect(nearlyEqual(dummySigner.LastSigned.Sub(time.Now()), 0)).To(BeTrue())
+		})
+
+		Context("A subsequent request is made", func() {
+			JustBeforeEach(func() {
+				rep.Body.Close()
+				req, err = http.NewRequest("GET", "/path/to/route", nil)
+				Expect(err).To(BeNil())
+				rep, err = sut.Do(nil, req, dummySigner, true)
+			})
+			It("signs it with the corrected time", func() {
+				Expect(err).To(BeNil())
+				Expect(rep.StatusCode).To(Equal(http.StatusOK))
+				Expect(requestCount()).To(Equal(3))
+			})
+		})
+
+		Context("The clock is accurate", func() {
+			BeforeEach(func() {
+				tp.SetNow(time.Now())
+				server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					mu.Lock()
+					numRequests++
+					mu.Unlock()
+					w.WriteHeader(http.StatusUnauthorized)
+				})
+			})
+			It("does not resend a rejected request", func() {
+				Expect(err).To(BeNil())
+				Expect(rep.StatusCode).To(Equal(http.StatusUnauthorized))
+				Expect(requestCount()).To(Equal(1))
+			})
+		})
+
+		Context("The body of the request cannot be re-read", func() {
+			BeforeEach(func() {
+				req.GetBody = nil
+			})
+			It("does not resend the request", func() {
+				Expect(err).To(BeNil())
+				Expect(rep.StatusCode).To(Equal(http.StatusUnauthorized))
+				Expect(requestCount()).To(Equal(1))
+			})
+		})
+	})
+})
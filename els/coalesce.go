@@ -0,0 +1,223 @@
+package els
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// flight is a request being made on behalf of one or more concurrent calls.
+type flight struct {
+	// done is closed when the request completes, after which cr and err are
+	// set.
+	done chan struct{}
+	cr   *CachedResponse
+	err  error
+
+	// call records the attempts made by the request.
+	call *Call
+
+	// waiters is the number of calls waiting for the request; when it falls
+	// to zero the request is cancelled.
+	waiters int
+	cancel  func()
+}
+
+// flightGroup holds the requests in flight, by key.
+type flightGroup struct {
+	sync.Mutex
+	flights map[string]*flight
+}
+
+// SetCoalescing determines whether identical concurrent GET requests are
+// coalesced: if on, a GET request made while an identical one (same URL,
+// isELSAPI and signing access key) is in flight is not sent, but waits for and
+// shares the response to the one in flight. The shared request is made with
+// the values (such as the trace context) and deadline of the context of the
+// call which started it, or the default timeout of the caller if it has no
+// deadline. Each call may stop waiting for it by cancelling its own context,
+// and the shared request is cancelled if all do. Each call is reported to
+// observers, but only the one which started the request records its attempts.
+// Only the URL is compared, so don't coalesce requests whose other headers
+// affect the response. Requests signed by signers which don't implement
+// KeyInfo are never coalesced.
+func (a *EDAPICaller) SetCoalescing(on bool) {
+	a.Lock()
+	defer a.Unlock()
+	if on && a.flights == nil {
+		a.flights = &flightGroup{flights: map[string]*flight{}}
+	} else if !on {
+		a.flights = nil
+	}
+}
+
+// getFlights returns the flight group, or nil if coalescing is off.
+func (a *EDAPICaller) getFlights() *flightGroup {
+	a.RLock()
+	defer a.RUnlock()
+	return a.flights
+}
+
+// coalesceKey returns the key which identifies request r, signed by s, for
+// coalescing, and false if it can't be coalesced.
+func coalesceKey(r *http.Request, s Signer, isELSAPI bool) (string, bool) {
+	if r.Method != "GET" {
+		return "", false
+	}
+
+	id := ""
+	if s != nil {
+		k, ok := s.(KeyInfo)
+		if !ok {
+			return "", false
+		}
+		id = string(k.AccessKeyID())
+	}
+
+	return r.Method + " " + strconv.FormatBool(isELSAPI) + " " + id + " " + r.URL.String(), true
+}
+
+// coalesce makes an attempt at call c with request r, joining the identical
+// request in flight under key if there is one, and otherwise starting one. The
+// attempts of a request started by c are recorded in c.
+func (a *EDAPICaller) coalesce(ctx context.Context, c *Call, g *flightGroup, key string, r *http.Request, o CallOptions) (*http.Response, error) {
+
+	g.Lock()
+	f, started := g.flights[key]
+	started = !started
+	if started {
+		f = a.startFlight(ctx, c, g, key, r, o)
+		g.flights[key] = f
+	}
+	f.waiters++
+	g.Unlock()
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		g.leave(key, f)
+		return nil, ctx.Err()
+	}
+
+	if started {
+		c.Attempts += f.call.Attempts
+		c.AttemptDurations = append(c.AttemptDurations, f.call.AttemptDurations...)
+		c.TimedOut = c.TimedOut || f.call.TimedOut
+		c.SignErr = f.call.SignErr
+	}
+	if rr := responseFromContext(ctx); rr != nil {
+		rr.Coalesced = true
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.cr.Response(r), nil
+}
+
+// startFlight starts making a copy of request r, for call c made with ctx and
+// options o, on behalf of the calls which will wait for the returned flight.
+func (a *EDAPICaller) startFlight(ctx context.Context, c *Call, g *flightGroup, key string, r *http.Request, o CallOptions) *flight {
+
+	var fctx context.Context = flightContext{ctx}
+	var fcancel func()
+	if d, ok := ctx.Deadline(); ok {
+		fctx, fcancel = context.WithDeadline(fctx, d)
+	} else {
+		fctx, fcancel = context.WithTimeout(fctx, a.requestTimeout)
+	}
+
+	// The attempts are recorded apart from c, as the call which started the
+	// flight may stop waiting for it.
+	fc := &Call{Operation: c.Operation, Method: c.Method, Route: c.Route, IsELSAPI: o.IsELSAPI, Signer: o.Signer, Start: time.Now()}
+	f := &flight{done: make(chan struct{}), call: fc, cancel: fcancel}
+
+	// The request is modified as it is made, so copy it in case the call which
+	// started the flight stops waiting and reuses it.
+	fr := copyRequest(fctx, r)
+
+	go func() {
+		defer fcancel()
+		f.cr, f.err = snapshot(a.call(fctx, fc, fr, o))
+
+		g.Lock()
+		if g.flights[key] == f {
+			delete(g.flights, key)
+		}
+		g.Unlock()
+
+		close(f.done)
+	}()
+
+	return f
+}
+
+// flightContext is the context of a request in flight: it has the values of
+// the context of the call which started it, apart from the call's Response,
+// but not its cancellation, as the call may stop waiting for the request while
+// others continue to.
+type flightContext struct {
+	context.Context
+}
+
+func (flightContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (flightContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (flightContext) Err() error {
+	return nil
+}
+
+func (c flightContext) Value(key interface{}) interface{} {
+	if key == (responseKey{}) {
+		return nil
+	}
+	return c.Context.Value(key)
+}
+
+// copyRequest returns a copy of r with context ctx, whose URL and headers can
+// be modified without affecting r. The body is shared.
+func copyRequest(ctx context.Context, r *http.Request) *http.Request {
+	c := r.WithContext(ctx)
+	u := *r.URL
+	c.URL = &u
+	c.Header = http.Header{}
+	for k, v := range r.Header {
+		c.Header[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+// leave records that a call has stopped waiting for flight f, cancelling it
+// if no calls are left waiting.
+func (g *flightGroup) leave(key string, f *flight) {
+	g.Lock()
+	defer g.Unlock()
+	f.waiters--
+	if f.waiters == 0 {
+		f.cancel()
+		if g.flights[key] == f {
+			delete(g.flights, key)
+		}
+	}
+}
+
+// snapshot reads and closes the body of resp so that it can be shared.
+func snapshot(resp *http.Response, err error) (*CachedResponse, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &CachedResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
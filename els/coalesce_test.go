@@ -0,0 +1,183 @@
+package els
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Coalesce Test Suite", func() {
+
+	var (
+		sut      *EDAPICaller
+		server   *httptest.Server
+		signer   *APISigner
+		release  chan struct{}
+		requests int32
+		observed atomic.Value
+
+		// waiters returns the number of calls waiting for the GET of path.
+		waiters = func(path string) int {
+			g := sut.getFlights()
+			g.Lock()
+			defer g.Unlock()
+			if f, ok := g.flights["GET true id1 "+path]; ok {
+				return f.waiters
+			}
+			return 0
+		}
+
+		// get makes a GET request and returns the body.
+		get = func(ctx context.Context) (string, error) {
+			rep, err := sut.Get(ctx, "/vendors/v1", signer, true)
+			if err != nil {
+				return "", err
+			}
+			defer rep.Body.Close()
+			b, err := ioutil.ReadAll(rep.Body)
+			return string(b), err
+		}
+	)
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&requests, 0)
+		release = make(chan struct{})
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			observed.Store(r.Header.Get("X-Observed-By"))
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+			w.Write([]byte("shared"))
+		}))
+		u, perr := url.Parse(server.URL)
+		Expect(perr).To(BeNil())
+
+		tp := datetime.NewNowTimeProvider()
+		tp.SetNow(time.Now())
+		sut = NewEDAPICaller(&http.Client{}, tp, 5*time.Second, "")
+		sut.APIHandler.Scheme = u.Scheme
+		sut.APIHandler.Domain = u.Host
+		sut.SetCoalescing(true)
+
+		var err error
+		signer, err = NewAPISigner(&AccessKey{ID: "id1", SecretAccessKey: "secret"})
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("sends one request for identical concurrent calls and shares the response", func() {
+		var wg sync.WaitGroup
+		bodies := make([]string, 5)
+		for i := range bodies {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				defer GinkgoRecover()
+				var err error
+				bodies[i], err = get(nil)
+				Expect(err).To(BeNil())
+			}(i)
+		}
+
+		Eventually(func() int { return waiters("/vendors/v1") }).Should(Equal(5))
+		close(release)
+		wg.Wait()
+
+		Expect(bodies).To(Equal([]string{"shared", "shared", "shared", "shared", "shared"}))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(1)))
+	})
+
+	It("reports each call to observers, sending with the values of the first's context", func() {
+		o := &recordingObserver{name: "o", events: &[]string{}}
+		sut.AddObserver(o)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer GinkgoRecover()
+				_, err := get(nil)
+				Expect(err).To(BeNil())
+			}()
+		}
+		Eventually(func() int { return waiters("/vendors/v1") }).Should(Equal(2))
+		close(release)
+		wg.Wait()
+
+		Expect(observed.Load()).To(Equal("o"))
+		Expect(*o.events).To(ConsistOf("o: started Get", "o: started Get", "o: send", "o: finished Get", "o: finished Get"))
+		Expect(o.finished[0].Attempts + o.finished[1].Attempts).To(Equal(1))
+	})
+
+	It("makes the shared request with the timeout of the first call", func() {
+		sut.requestTimeout = 10 * time.Millisecond
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			close(release)
+		}()
+		rep, err := sut.GetWith(nil, "/vendors/v1", WithSigner(signer), WithTimeout(5*time.Second))
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+	})
+
+	It("lets each call stop waiting by cancelling its own context", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+		go func() {
+			_, err := get(ctx)
+			errs <- err
+		}()
+		bodies := make(chan string, 1)
+		go func() {
+			defer GinkgoRecover()
+			b, err := get(nil)
+			Expect(err).To(BeNil())
+			bodies <- b
+		}()
+
+		Eventually(func() int { return waiters("/vendors/v1") }).Should(Equal(2))
+		cancel()
+		Eventually(errs).Should(Receive(Equal(context.Canceled)))
+
+		close(release)
+		Eventually(bodies).Should(Receive(Equal("shared")))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(1)))
+	})
+
+	It("cancels the request when no calls are left waiting", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+		go func() {
+			_, err := get(ctx)
+			errs <- err
+		}()
+
+		Eventually(func() int32 { return atomic.LoadInt32(&requests) }).Should(Equal(int32(1)))
+		cancel()
+		Eventually(errs).Should(Receive(Equal(context.Canceled)))
+		Eventually(func() int { return waiters("/vendors/v1") }).Should(Equal(0))
+
+		close(release)
+		b, err := get(nil)
+		Expect(err).To(BeNil())
+		Expect(b).To(Equal("shared"))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+	})
+})
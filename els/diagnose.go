@@ -0,0 +1,208 @@
+package els
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// FingerprintComponent is a single named part of a fingerprint (the string
+// which is signed), such as the method or the body hash.
+type FingerprintComponent struct {
+	Name  string
+	Value string
+}
+
+// ComponentDiff records a fingerprint component whose value differs between
+// two fingerprints.
+type ComponentDiff struct {
+	Name     string
+	Local    string
+	Captured string
+}
+
+// Diagnosis is the result of comparing a locally computed fingerprint with the
+// fingerprint of a captured request. Use String() for a human-readable report.
+type Diagnosis struct {
+	// Scheme is the signing scheme of the captured request.
+	Scheme SigningScheme
+
+	// LocalFingerprint is the fingerprint passed to Diagnose.
+	LocalFingerprint string
+
+	// CapturedFingerprint is the fingerprint derived from the captured
+	// request.
+	CapturedFingerprint string
+
+	// Diffs lists the components which differ, in fingerprint order.
+	Diffs []ComponentDiff
+
+	// SignatureValid records whether the signature carried by the captured
+	// request is the one the signer's access key produces for the captured
+	// fingerprint. If not, the request was signed with a different key or
+	// altered after signing.
+	SignatureValid bool
+}
+
+// Match returns true if the fingerprints are identical.
+func (d *Diagnosis) Match() bool {
+	return len(d.Diffs) == 0
+}
+
+// String returns a human-readable report of the diagnosis.
+func (d *Diagnosis) String() string {
+	b := &bytes.Buffer{}
+
+	fmt.Fprintf(b, "ELS signature diagnosis (scheme V%d)\n", int(d.Scheme)+1)
+
+	if d.Match() {
+		fmt.Fprintf(b, "  fingerprints match\n")
+	}
+	for _, c := range d.Diffs {
+		fmt.Fprintf(b, "  %s differs:\n    local:    %q\n    captured: %q\n", c.Name, c.Local, c.Captured)
+	}
+
+	if d.SignatureValid {
+		fmt.Fprintf(b, "  captured signature is valid for the captured request\n")
+	} else {
+		fmt.Fprintf(b, "  captured signature is NOT valid for the captured request: it was signed with a different key or altered after signing\n")
+	}
+
+	return b.String()
+}
+
+// Diagnose compares localFingerprint - typically obtained from StringToSign or
+// from the debug log when the request was signed - with the fingerprint
+// derived from captured, a copy of the request as received by the server
+// (e.g. from a proxy log or a test server). The captured request's date (and,
+// for SchemeV2, its list of signed headers) is used to derive its fingerprint,
+// so the two will match if the request reached the server as it was signed.
+// The report identifies which components differ.
+func (s *APISigner) Diagnose(localFingerprint string, captured *http.Request) (*Diagnosis, error) {
+
+	if captured == nil {
+		return nil, ErrNoRequest
+	}
+
+	scheme, id, sig := parseAuthorization(captured.Header.Get("Authorization"))
+	if id == "" {
+		scheme = s.scheme
+	}
+
+	utcStr := captured.Header.Get("X-Els-Date")
+
+	var fp string
+	var err error
+	if scheme == SchemeV2 {
+		fp, err = v2Fingerprint(captured, utcStr)
+	} else {
+		fp, err = requestFingerprint(captured, utcStr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Diagnosis{
+		Scheme:              scheme,
+		LocalFingerprint:    localFingerprint,
+		CapturedFingerprint: fp,
+		Diffs:               diffComponents(FingerprintComponents(scheme, localFingerprint), FingerprintComponents(scheme, fp)),
+		SignatureValid:      id == s.accessKey.ID && checkSignature(s.accessKey, fp, sig) == nil,
+	}
+
+	return d, nil
+}
+
+// FingerprintComponents splits a fingerprint produced with the given scheme
+// into its named components.
+func FingerprintComponents(scheme SigningScheme, fingerprint string) []FingerprintComponent {
+
+	lines := strings.Split(fingerprint, "\n")
+	cs := []FingerprintComponent{}
+
+	named := func(names []string, values []string) {
+		for i, n := range names {
+			v := ""
+			if i < len(values) {
+				v = values[i]
+			}
+			cs = append(cs, FingerprintComponent{n, v})
+		}
+	}
+
+	if scheme != SchemeV2 {
+		named([]string{"method", "body hash", "content type", "date", "path"}, lines)
+		return cs
+	}
+
+	head := []string{"algorithm", "date", "method", "path", "query"}
+	if len(lines) < len(head)+2 {
+		named(append(head, "signed headers", "body hash"), lines)
+		return cs
+	}
+
+	named(head, lines)
+	for _, l := range lines[len(head) : len(lines)-2] {
+		parts := strings.SplitN(l, ":", 2)
+		if len(parts) < 2 {
+			parts = append(parts, "")
+		}
+		cs = append(cs, FingerprintComponent{"header " + parts[0], parts[1]})
+	}
+	named([]string{"signed headers", "body hash"}, lines[len(lines)-2:])
+
+	return cs
+}
+
+// diffComponents returns the components whose values differ between local
+// and captured, including those present in only one of them.
+func diffComponents(local []FingerprintComponent, captured []FingerprintComponent) []ComponentDiff {
+
+	cv := map[string]string{}
+	for _, c := range captured {
+		cv[c.Name] = c.Value
+	}
+
+	diffs := []ComponentDiff{}
+	seen := map[string]bool{}
+
+	for _, c := range local {
+		seen[c.Name] = true
+		if v, ok := cv[c.Name]; !ok || v != c.Value {
+			diffs = append(diffs, ComponentDiff{c.Name, c.Value, v})
+		}
+	}
+
+	for _, c := range captured {
+		if !seen[c.Name] {
+			diffs = append(diffs, ComponentDiff{c.Name, "", c.Value})
+		}
+	}
+
+	return diffs
+}
+
+// parseAuthorization returns the scheme, access key ID and signature in the
+// Authorization header of an ELS-signed request. The ID is empty if the header
+// is not an ELS signature.
+func parseAuthorization(auth string) (SigningScheme, AccessKeyID, string) {
+
+	scheme := SchemeV1
+	switch {
+	case strings.HasPrefix(auth, v2AuthPrefix):
+		scheme = SchemeV2
+		auth = strings.TrimPrefix(auth, v2AuthPrefix)
+	case strings.HasPrefix(auth, "ELS "):
+		auth = strings.TrimPrefix(auth, "ELS ")
+	default:
+		return scheme, "", ""
+	}
+
+	parts := strings.SplitN(auth, ":", 2)
+	if len(parts) != 2 {
+		return scheme, "", ""
+	}
+
+	return scheme, AccessKeyID(parts[0]), parts[1]
+}
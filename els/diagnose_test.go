@@ -0,0 +1,153 @@
+package els
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diagnose Test Suite", func() {
+
+	var (
+		now, _ = time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+		k      *AccessKey
+		sut    *APISigner
+		r      *http.Request
+		fp     string
+		d      *Diagnosis
+		err    error
+
+		newRequest = func() *http.Request {
+			req, rerr := http.NewRequest("POST", "https://api.elasticlicensing.com/1.0/path/to/route?a=1", bytes.NewBufferString(`{"a":"b"}`))
+			Expect(rerr).To(BeNil())
+			req.Header.Set("X-Request-Id", "abc")
+			return req
+		}
+	)
+
+	BeforeEach(func() {
+		k = &AccessKey{ID: "AccessKeyID", SecretAccessKey: "secretAccessKey"}
+		sut, err = NewAPISigner(k)
+		Expect(err).To(BeNil())
+	})
+
+	Describe("StringToSign", func() {
+		BeforeEach(func() {
+			r = newRequest()
+		})
+
+		It("returns the fingerprint without signing the request", func() {
+			fp, err = sut.StringToSign(r, now)
+			Expect(err).To(BeNil())
+			Expect(fp).To(Equal("POST\n" +
+				"92eff9dda44cb8003ee13990782580ff\n" +
+				"application/json;charset=utf-8\n" +
+				"2015-01-01T00:00:00Z\n" +
+				"/1.0/path/to/route"))
+			Expect(r.Header.Get("Authorization")).To(BeEmpty())
+		})
+
+		Context("The signer uses SchemeV2", func() {
+			BeforeEach(func() {
+				sut, err = NewAPISignerV2(k, "X-Request-Id")
+				Expect(err).To(BeNil())
+			})
+			It("returns the fingerprint which Sign signs", func() {
+				fp, err = sut.StringToSign(r, now)
+				Expect(err).To(BeNil())
+				Expect(r.Header.Get("X-Els-Date")).To(BeEmpty())
+
+				Expect(sut.Sign(r, now)).To(BeNil())
+				d, err = sut.Diagnose(fp, r)
+				Expect(err).To(BeNil())
+				Expect(d.Match()).To(BeTrue())
+				Expect(d.SignatureValid).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Diagnose", func() {
+		var captured *http.Request
+
+		BeforeEach(func() {
+			r = newRequest()
+			fp, err = sut.StringToSign(r, now)
+			Expect(err).To(BeNil())
+			Expect(sut.Sign(r, now)).To(BeNil())
+			captured = r
+		})
+
+		JustBeforeEach(func() {
+			d, err = sut.Diagnose(fp, captured)
+		})
+
+		Context("The request reached the server as it was signed", func() {
+			It("reports a match", func() {
+				Expect(err).To(BeNil())
+				Expect(d.Match()).To(BeTrue())
+				Expect(d.SignatureValid).To(BeTrue())
+				Expect(d.String()).To(ContainSubstring("fingerprints match"))
+			})
+		})
+
+		Context("The path was altered by a proxy", func() {
+			BeforeEach(func() {
+				captured.URL.Path = "/1.0/path/to/other"
+			})
+			It("reports the path as differing", func() {
+				Expect(err).To(BeNil())
+				Expect(d.Diffs).To(Equal([]ComponentDiff{{"path", "/1.0/path/to/route", "/1.0/path/to/other"}}))
+				Expect(d.SignatureValid).To(BeFalse())
+				Expect(d.String()).To(ContainSubstring("path differs"))
+			})
+		})
+
+		Context("The request was signed with a different secret", func() {
+			BeforeEach(func() {
+				other, serr := NewAPISigner(&AccessKey{ID: "AccessKeyID", SecretAccessKey: "another"})
+				Expect(serr).To(BeNil())
+				captured = newRequest()
+				Expect(other.Sign(captured, now)).To(BeNil())
+			})
+			It("reports matching fingerprints but an invalid signature", func() {
+				Expect(err).To(BeNil())
+				Expect(d.Match()).To(BeTrue())
+				Expect(d.SignatureValid).To(BeFalse())
+			})
+		})
+
+		Context("The request uses SchemeV2 and a signed header was altered", func() {
+			BeforeEach(func() {
+				sut, err = NewAPISignerV2(k, "X-Request-Id")
+				Expect(err).To(BeNil())
+				r = newRequest()
+				fp, err = sut.StringToSign(r, now)
+				Expect(err).To(BeNil())
+				Expect(sut.Sign(r, now)).To(BeNil())
+				r.Header.Set("X-Request-Id", "def")
+				captured = r
+			})
+			It("reports the header as differing", func() {
+				Expect(err).To(BeNil())
+				Expect(d.Scheme).To(Equal(SchemeV2))
+				Expect(d.Diffs).To(Equal([]ComponentDiff{{"header x-request-id", "abc", "def"}}))
+			})
+		})
+	})
+
+	Describe("FingerprintComponents", func() {
+		It("names the components of a SchemeV1 fingerprint", func() {
+			cs := FingerprintComponents(SchemeV1, "GET\n\n\n2015-01-01T00:00:00Z\n/1.0/route")
+			Expect(cs).To(Equal([]FingerprintComponent{
+				{"method", "GET"},
+				{"body hash", ""},
+				{"content type", ""},
+				{"date", "2015-01-01T00:00:00Z"},
+				{"path", "/1.0/route"},
+			}))
+		})
+	})
+})
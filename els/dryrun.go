@@ -0,0 +1,61 @@
+package els
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// errDryRun stops a dry run (see DryRun) where the request would be sent.
+var errDryRun = errors.New("Dry Run")
+
+// dryRun records the request of a call made by DryRun.
+type dryRun struct {
+	request *http.Request
+}
+
+// dryRunKey is the context key under which the dryRun of a call made by DryRun
+// is stored.
+type dryRunKey struct{}
+
+// dryRunFromContext returns the dryRun of the call made with ctx, or nil if it
+// is not a dry run.
+func dryRunFromContext(ctx context.Context) *dryRun {
+	if ctx == nil {
+		return nil
+	}
+	d, _ := ctx.Value(dryRunKey{}).(*dryRun)
+	return d
+}
+
+// DryRun completes and signs request r exactly as DoWith would with the same
+// options, running the interceptors of the StageBeforeSign and StageAfterSign
+// stages, but returns the request which would be sent rather than sending it.
+// Use CurlCommand, HTTPieCommand or NewHAREntry to reproduce it elsewhere.
+// The cache, coalescing and retries don't apply, observers are not notified
+// and, if failover is configured, the request is for the endpoint which would
+// be tried first. As with Do, r itself may be modified. The request returned
+// has context ctx (or the background context if ctx is nil) rather than the
+// call's, so that it can be sent once DryRun returns.
+func (a *EDAPICaller) DryRun(ctx context.Context, r *http.Request, opts ...CallOption) (*http.Request, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	d := &dryRun{}
+
+	_, err := a.doWith(context.WithValue(ctx, dryRunKey{}, d), r, NewCallOptions(opts...), OpDo)
+	if err != nil && err != errDryRun {
+		return nil, err
+	}
+	return d.request.WithContext(ctx), nil
+}
+
+// getCallObservers returns the observers to notify of the call made with ctx:
+// none if it is a dry run.
+func (a *EDAPICaller) getCallObservers(ctx context.Context) []CallObserver {
+	if dryRunFromContext(ctx) != nil {
+		return nil
+	}
+	return a.getObservers()
+}
@@ -0,0 +1,128 @@
+package els
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dry Run Test Suite", func() {
+
+	var (
+		now, _ = time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+		k      = &AccessKey{ID: "id", SecretAccessKey: "secret"}
+		server *httptest.Server
+		sent   int32
+		sut    *EDAPICaller
+		signer *APISigner
+	)
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&sent, 0)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&sent, 1)
+		}))
+		u, err := url.Parse(server.URL)
+		Expect(err).To(BeNil())
+
+		tp := datetime.NewNowTimeProvider()
+		tp.SetNow(now)
+		sut = NewEDAPICaller(&http.Client{}, tp, time.Second, "")
+		sut.APIHandler.Scheme = u.Scheme
+		sut.APIHandler.Domain = u.Host
+
+		signer, err = NewAPISigner(k)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("completes and signs the request without sending it", func() {
+		observer := &recordingObserver{name: "o", events: &[]string{}}
+		sut.AddObserver(observer)
+		stages := []Stage{}
+		for _, st := range []Stage{StageBeforeSign, StageAfterSign, StageAfterResponse} {
+			st := st
+			sut.Use(st, InterceptorFunc(func(ctx context.Context, e *Exchange) error {
+				stages = append(stages, st)
+				return nil
+			}))
+		}
+
+		r, err := http.NewRequest("POST", "/vendors/v1", strings.NewReader(`{"a":1}`))
+		Expect(err).To(BeNil())
+		req, err := sut.DryRun(nil, r, WithSigner(signer), WithRetry(3, 0))
+		Expect(err).To(BeNil())
+
+		Expect(atomic.LoadInt32(&sent)).To(Equal(int32(0)))
+		Expect(req.URL.String()).To(Equal(server.URL + "/1.0/vendors/v1"))
+		Expect(req.Header.Get(HeaderIdempotencyKey)).NotTo(BeEmpty())
+		Expect(stages).To(Equal([]Stage{StageBeforeSign, StageAfterSign}))
+		Expect(*observer.events).To(BeEmpty())
+
+		v := NewVerifier(KeyStoreFunc(func(id AccessKeyID) (*AccessKey, error) {
+			return k, nil
+		}))
+		_, err = v.Verify(req, now)
+		Expect(err).To(BeNil())
+	})
+
+	It("returns a request which can be sent", func() {
+		r, err := http.NewRequest("GET", "/vendors/v1", nil)
+		Expect(err).To(BeNil())
+		req, err := sut.DryRun(nil, r, WithSigner(signer))
+		Expect(err).To(BeNil())
+
+		rep, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(atomic.LoadInt32(&sent)).To(Equal(int32(1)))
+	})
+
+	It("doesn't take the response from the cache", func() {
+		sut.SetCache(NewMemoryCache(10))
+		server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=60")
+		})
+		rep, err := sut.Get(nil, "/vendors/v1", signer, true)
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+
+		r, err := http.NewRequest("GET", "/vendors/v1", nil)
+		Expect(err).To(BeNil())
+		req, err := sut.DryRun(context.Background(), r, WithSigner(signer))
+		Expect(err).To(BeNil())
+		Expect(req.Header.Get("Authorization")).To(HavePrefix("ELS id:"))
+	})
+
+	It("targets the first endpoint without marking it failed", func() {
+		sut.SetEndpoints(time.Minute,
+			Endpoint{Name: "primary", Scheme: "https", Domain: "primary.example.com"},
+			Endpoint{Name: "secondary", Scheme: "https", Domain: "secondary.example.com"})
+		r, err := http.NewRequest("GET", "/vendors/v1", nil)
+		Expect(err).To(BeNil())
+		req, err := sut.DryRun(nil, r, WithSigner(signer))
+		Expect(err).To(BeNil())
+		Expect(req.URL.Host).To(Equal("primary.example.com"))
+		Expect(sut.Endpoints()[0].ConsecutiveFailures).To(Equal(0))
+	})
+
+	It("returns signing errors", func() {
+		r, err := http.NewRequest("GET", "/vendors/v1", nil)
+		Expect(err).To(BeNil())
+		signer.SetAPIVersions("2.0")
+		_, err = sut.DryRun(nil, r, WithSigner(signer))
+		Expect(err).To(Equal(ErrRequestInvalidURL))
+	})
+})
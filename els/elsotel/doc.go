@@ -0,0 +1,5 @@
+// Package elsotel instruments els.EDAPICaller with OpenTelemetry: it creates a
+// span for each API call, propagates trace context in the request headers and
+// records call latency, errors and the number of calls in flight. Add the
+// Observer returned by NewObserver with EDAPICaller.AddObserver.
+package elsotel
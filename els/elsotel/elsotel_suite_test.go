@@ -0,0 +1,14 @@
+package elsotel
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestElsotel(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "elsotel Suite")
+}
@@ -0,0 +1,183 @@
+package elsotel
+
+import (
+	"net/http"
+
+	"github.com/elasticlic/els-api-sdk-go/els"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+// InstrumentationName identifies this package as the source of spans and
+// metrics.
+const InstrumentationName = "github.com/elasticlic/els-api-sdk-go/els/elsotel"
+
+// The names of the metrics recorded by an Observer.
+const (
+	MetricDuration = "els.client.duration"
+	MetricErrors   = "els.client.errors"
+	MetricInFlight = "els.client.in_flight"
+)
+
+// The attributes recorded on spans and metrics.
+const (
+	AttrOperation      = attribute.Key("els.operation")
+	AttrRoute          = attribute.Key("els.route")
+	AttrELSAPI         = attribute.Key("els.api")
+	AttrAttempts       = attribute.Key("els.attempts")
+	AttrIdempotencyKey = attribute.Key("els.idempotency_key")
+	AttrMethod         = attribute.Key("http.request.method")
+	AttrStatusCode     = attribute.Key("http.response.status_code")
+)
+
+// config holds the settings of an Observer.
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagator     propagation.TextMapPropagator
+}
+
+// Option configures an Observer.
+type Option func(*config)
+
+// WithTracerProvider sets the provider of the tracer used to create spans. The
+// global provider is used by default.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider sets the provider of the meter used to record metrics. The
+// global provider is used by default.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// WithPropagator sets the propagator used to add trace context to request
+// headers. The global propagator is used by default.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = p
+	}
+}
+
+// Observer implements interface els.CallObserver using OpenTelemetry.
+type Observer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	duration   metric.Float64Histogram
+	errors     metric.Int64Counter
+	inFlight   metric.Int64UpDownCounter
+}
+
+// NewObserver returns an Observer configured with the given options. Add it to
+// an EDAPICaller with AddObserver.
+func NewObserver(opts ...Option) (*Observer, error) {
+	c := &config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+		propagator:     otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	meter := c.meterProvider.Meter(InstrumentationName)
+
+	o := &Observer{
+		tracer:     c.tracerProvider.Tracer(InstrumentationName),
+		propagator: c.propagator,
+	}
+
+	var err error
+
+	o.duration, err = meter.Float64Histogram(MetricDuration,
+		metric.WithDescription("Duration of API calls made by an EDAPICaller"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	o.errors, err = meter.Int64Counter(MetricErrors,
+		metric.WithDescription("Number of API calls which returned an error"))
+	if err != nil {
+		return nil, err
+	}
+
+	o.inFlight, err = meter.Int64UpDownCounter(MetricInFlight,
+		metric.WithDescription("Number of API calls in progress"))
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// CallStarted implements interface els.CallObserver by starting a span for the
+// call.
+func (o *Observer) CallStarted(ctx context.Context, c *els.Call) context.Context {
+	ctx, _ = o.tracer.Start(ctx, "ELS "+c.Operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(callAttributes(c)...))
+
+	o.inFlight.Add(ctx, 1, metric.WithAttributes(callAttributes(c)...))
+
+	return ctx
+}
+
+// BeforeSend implements interface els.CallObserver by adding the trace context
+// of the call's span to the request headers.
+func (o *Observer) BeforeSend(ctx context.Context, c *els.Call, r *http.Request) {
+	o.propagator.Inject(ctx, propagation.HeaderCarrier(r.Header))
+}
+
+// CallFinished implements interface els.CallObserver by recording the outcome
+// of the call in its span and metrics.
+func (o *Observer) CallFinished(ctx context.Context, c *els.Call) {
+	attrs := callAttributes(c)
+	o.inFlight.Add(ctx, -1, metric.WithAttributes(attrs...))
+
+	if c.StatusCode != 0 {
+		attrs = append(attrs, AttrStatusCode.Int(c.StatusCode))
+	}
+
+	o.duration.Record(ctx, c.Duration.Seconds(), metric.WithAttributes(attrs...))
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(AttrAttempts.Int(c.Attempts))
+	if c.IdempotencyKey != "" {
+		// Recorded on the span only, as it is unique to the call.
+		span.SetAttributes(AttrIdempotencyKey.String(c.IdempotencyKey))
+	}
+	if c.StatusCode != 0 {
+		span.SetAttributes(AttrStatusCode.Int(c.StatusCode))
+	}
+
+	if c.Err != nil {
+		o.errors.Add(ctx, 1, metric.WithAttributes(attrs...))
+		span.RecordError(c.Err)
+		span.SetStatus(codes.Error, c.Err.Error())
+	} else if c.StatusCode >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(c.StatusCode))
+	}
+
+	span.End()
+}
+
+// callAttributes returns the attributes which identify the call.
+func callAttributes(c *els.Call) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		AttrOperation.String(c.Operation),
+		AttrMethod.String(c.Method),
+		AttrRoute.String(c.Route),
+		AttrELSAPI.Bool(c.IsELSAPI),
+	}
+}
@@ -0,0 +1,165 @@
+package elsotel
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/elasticlic/els-api-sdk-go/els"
+	"github.com/elasticlic/go-utils/datetime"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Observer Test Suite", func() {
+
+	var (
+		caller     *els.EDAPICaller
+		server     *httptest.Server
+		spans      *tracetest.SpanRecorder
+		reader     *sdkmetric.ManualReader
+		sut        *Observer
+		statusCode int
+		reqRec     *http.Request
+		err        error
+
+		// collect returns the metrics recorded so far, by name.
+		collect = func() map[string]metricdata.Aggregation {
+			rm := metricdata.ResourceMetrics{}
+			Expect(reader.Collect(context.Background(), &rm)).To(Succeed())
+			ms := map[string]metricdata.Aggregation{}
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					ms[m.Name] = m.Data
+				}
+			}
+			return ms
+		}
+	)
+
+	log.SetOutput(ioutil.Discard)
+
+	BeforeEach(func() {
+		statusCode = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqRec = r
+			w.WriteHeader(statusCode)
+		}))
+		u, perr := url.Parse(server.URL)
+		Expect(perr).To(BeNil())
+
+		caller = els.NewEDAPICaller(&http.Client{}, datetime.NewNowTimeProvider(), time.Second, "")
+		caller.APIHandler.Scheme = u.Scheme
+		caller.APIHandler.Domain = u.Host
+
+		spans = tracetest.NewSpanRecorder()
+		reader = sdkmetric.NewManualReader()
+
+		sut, err = NewObserver(
+			WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+			WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+			WithPropagator(propagation.TraceContext{}))
+		Expect(err).To(BeNil())
+		caller.AddObserver(sut)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("A successful call", func() {
+		JustBeforeEach(func() {
+			ctx := els.WithRoute(context.Background(), "/vendors/{id}")
+			rep, derr := caller.Get(ctx, "/vendors/v1", nil, true)
+			Expect(derr).To(BeNil())
+			rep.Body.Close()
+		})
+
+		It("records a span for the call", func() {
+			ss := spans.Ended()
+			Expect(ss).To(HaveLen(1))
+			s := ss[0]
+			Expect(s.Name()).To(Equal("ELS Get"))
+			Expect(s.Attributes()).To(ContainElement(AttrRoute.String("/vendors/{id}")))
+			Expect(s.Attributes()).To(ContainElement(AttrMethod.String("GET")))
+			Expect(s.Attributes()).To(ContainElement(AttrStatusCode.Int(200)))
+			Expect(s.Attributes()).To(ContainElement(AttrAttempts.Int(1)))
+			Expect(s.Status().Code).To(Equal(codes.Unset))
+		})
+
+		It("propagates the trace context", func() {
+			tp := reqRec.Header.Get("Traceparent")
+			Expect(tp).To(ContainSubstring(spans.Ended()[0].SpanContext().TraceID().String()))
+		})
+
+		It("records the latency and in-flight metrics", func() {
+			ms := collect()
+			h := ms[MetricDuration].(metricdata.Histogram[float64])
+			Expect(h.DataPoints).To(HaveLen(1))
+			Expect(h.DataPoints[0].Count).To(Equal(uint64(1)))
+			f := ms[MetricInFlight].(metricdata.Sum[int64])
+			Expect(f.DataPoints[0].Value).To(Equal(int64(0)))
+			Expect(ms).NotTo(HaveKey(MetricErrors))
+		})
+	})
+
+	Describe("A POST call", func() {
+		JustBeforeEach(func() {
+			r, rerr := http.NewRequest("POST", "/vendors/v1", nil)
+			Expect(rerr).To(BeNil())
+			rep, derr := caller.Do(nil, r, nil, true)
+			Expect(derr).To(BeNil())
+			rep.Body.Close()
+		})
+
+		It("records its idempotency key in the span", func() {
+			key := reqRec.Header.Get(els.HeaderIdempotencyKey)
+			Expect(key).NotTo(BeEmpty())
+			Expect(spans.Ended()[0].Attributes()).To(ContainElement(AttrIdempotencyKey.String(key)))
+		})
+	})
+
+	Describe("A failed call", func() {
+		JustBeforeEach(func() {
+			server.Close()
+			_, err = caller.Get(nil, "/vendors/v1", nil, true)
+		})
+
+		It("records the error in the span and the error counter", func() {
+			Expect(err).NotTo(BeNil())
+			s := spans.Ended()[0]
+			Expect(s.Status().Code).To(Equal(codes.Error))
+			Expect(s.Events()).NotTo(BeEmpty())
+			e := collect()[MetricErrors].(metricdata.Sum[int64])
+			Expect(e.DataPoints[0].Value).To(Equal(int64(1)))
+		})
+	})
+
+	Describe("CreateAccessKey", func() {
+		BeforeEach(func() {
+			statusCode = http.StatusUnauthorized
+		})
+		JustBeforeEach(func() {
+			_, _, err = caller.CreateAccessKey(context.Background(), "a@b.com", "pw", false, 0)
+		})
+		It("records a span with the route template", func() {
+			Expect(err).To(Equal(els.ErrUnexpectedStatusCode))
+			s := spans.Ended()[0]
+			Expect(s.Name()).To(Equal("ELS CreateAccessKey"))
+			Expect(s.Attributes()).To(ContainElement(AttrRoute.String("/users/{email}/accessKeys")))
+			Expect(s.Attributes()).To(ContainElement(AttrStatusCode.Int(401)))
+			Expect(reqRec.Header.Get("Traceparent")).NotTo(BeEmpty())
+		})
+	})
+})
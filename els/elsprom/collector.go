@@ -0,0 +1,137 @@
+package elsprom
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/elasticlic/els-api-sdk-go/els"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Namespace prefixes the names of all the metrics of a Collector.
+const Namespace = "els_client"
+
+// The names of the metrics recorded by a Collector, without the namespace.
+const (
+	MetricRequests        = "requests_total"
+	MetricDuration        = "request_duration_seconds"
+	MetricTimeouts        = "timeouts_total"
+	MetricSigningFailures = "signing_failures_total"
+	MetricKeyExpiry       = "access_key_expiry_seconds"
+)
+
+// Collector implements interfaces els.MetricsCollector and
+// prometheus.Collector. Register it with a prometheus.Registerer and add it to
+// an EDAPICaller with AddMetricsCollector.
+type Collector struct {
+	requests        *prometheus.CounterVec
+	duration        *prometheus.HistogramVec
+	timeouts        *prometheus.CounterVec
+	signingFailures *prometheus.CounterVec
+	keyExpiry       *prometheus.Desc
+
+	// expiries holds the expiry date of each access key which has signed a
+	// request, from which the countdown is calculated when metrics are
+	// collected.
+	mu       sync.Mutex
+	expiries map[els.AccessKeyID]time.Time
+
+	// now returns the current time; it is replaced in tests.
+	now func() time.Time
+}
+
+// NewCollector returns a Collector whose latency histogram uses the given
+// buckets, or prometheus.DefBuckets if none are given.
+func NewCollector(buckets ...float64) *Collector {
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      MetricRequests,
+			Help:      "Number of requests sent by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      MetricDuration,
+			Help:      "Duration of requests by route and method.",
+			Buckets:   buckets,
+		}, []string{"route", "method"}),
+		timeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      MetricTimeouts,
+			Help:      "Number of requests which failed to get a response, by route.",
+		}, []string{"route"}),
+		signingFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      MetricSigningFailures,
+			Help:      "Number of requests which could not be signed, by route and error type.",
+		}, []string{"route", "type"}),
+		keyExpiry: prometheus.NewDesc(
+			prometheus.BuildFQName(Namespace, "", MetricKeyExpiry),
+			"Seconds until the access key expires; negative once it has expired.",
+			[]string{"access_key_id"}, nil),
+		expiries: map[els.AccessKeyID]time.Time{},
+		now:      time.Now,
+	}
+}
+
+// ObserveRequest implements interface els.MetricsCollector. Requests which got
+// no response are recorded with the status "none".
+func (c *Collector) ObserveRequest(route, method string, statusCode int, d time.Duration) {
+	status := "none"
+	if statusCode != 0 {
+		status = strconv.Itoa(statusCode)
+	}
+	c.requests.WithLabelValues(route, method, status).Inc()
+	c.duration.WithLabelValues(route, method).Observe(d.Seconds())
+}
+
+// IncTimeout implements interface els.MetricsCollector.
+func (c *Collector) IncTimeout(route string) {
+	c.timeouts.WithLabelValues(route).Inc()
+}
+
+// IncSigningFailure implements interface els.MetricsCollector.
+func (c *Collector) IncSigningFailure(route, errType string) {
+	c.signingFailures.WithLabelValues(route, errType).Inc()
+}
+
+// ObserveKeyExpiry implements interface els.MetricsCollector. Keys which never
+// expire are not reported.
+func (c *Collector) ObserveKeyExpiry(id els.AccessKeyID, expiry time.Time) {
+	if expiry.IsZero() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expiries[id] = expiry
+}
+
+// Describe implements interface prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.duration.Describe(ch)
+	c.timeouts.Describe(ch)
+	c.signingFailures.Describe(ch)
+	ch <- c.keyExpiry
+}
+
+// Collect implements interface prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.duration.Collect(ch)
+	c.timeouts.Collect(ch)
+	c.signingFailures.Collect(ch)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for id, expiry := range c.expiries {
+		ch <- prometheus.MustNewConstMetric(c.keyExpiry, prometheus.GaugeValue,
+			expiry.Sub(now).Seconds(), string(id))
+	}
+}
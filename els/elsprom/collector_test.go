@@ -0,0 +1,138 @@
+package elsprom
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/elasticlic/els-api-sdk-go/els"
+	"github.com/elasticlic/go-utils/datetime"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Collector Test Suite", func() {
+
+	var (
+		caller     *els.EDAPICaller
+		server     *httptest.Server
+		now        time.Time
+		signer     *els.APISigner
+		sut        *Collector
+		statusCode int
+		err        error
+
+		// gather returns the metric families registered by sut, by name.
+		gather = func() map[string]*dto.MetricFamily {
+			reg := prometheus.NewPedanticRegistry()
+			Expect(reg.Register(sut)).To(Succeed())
+			mfs, gerr := reg.Gather()
+			Expect(gerr).To(BeNil())
+			ms := map[string]*dto.MetricFamily{}
+			for _, mf := range mfs {
+				ms[mf.GetName()] = mf
+			}
+			return ms
+		}
+	)
+
+	log.SetOutput(ioutil.Discard)
+
+	BeforeEach(func() {
+		statusCode = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(statusCode)
+		}))
+		u, perr := url.Parse(server.URL)
+		Expect(perr).To(BeNil())
+
+		now = time.Now()
+		tp := datetime.NewNowTimeProvider()
+		tp.SetNow(now)
+		caller = els.NewEDAPICaller(&http.Client{}, tp, time.Second, "")
+		caller.APIHandler.Scheme = u.Scheme
+		caller.APIHandler.Domain = u.Host
+
+		signer, err = els.NewAPISigner(&els.AccessKey{
+			ID:              "AccessKeyID",
+			SecretAccessKey: "secret",
+			ExpiryDate:      now.Add(time.Hour),
+		})
+		Expect(err).To(BeNil())
+
+		sut = NewCollector()
+		sut.now = func() time.Time { return now }
+		caller.AddMetricsCollector(sut)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Successful calls", func() {
+		JustBeforeEach(func() {
+			ctx := els.WithRoute(context.Background(), "/vendors/{id}")
+			for i := 0; i < 2; i++ {
+				rep, derr := caller.Get(ctx, "/vendors/v1", signer, true)
+				Expect(derr).To(BeNil())
+				rep.Body.Close()
+			}
+		})
+
+		It("counts the requests by route and status", func() {
+			Expect(testutil.ToFloat64(sut.requests.WithLabelValues("/vendors/{id}", "GET", "200"))).To(Equal(2.0))
+			h := gather()[Namespace+"_"+MetricDuration]
+			Expect(h.GetMetric()[0].GetHistogram().GetSampleCount()).To(Equal(uint64(2)))
+		})
+
+		It("reports the countdown to the access key's expiry", func() {
+			now = now.Add(10 * time.Minute)
+			g := gather()[Namespace+"_"+MetricKeyExpiry]
+			Expect(g.GetMetric()).To(HaveLen(1))
+			Expect(g.GetMetric()[0].GetGauge().GetValue()).To(Equal((50 * time.Minute).Seconds()))
+			Expect(g.GetMetric()[0].GetLabel()[0].GetValue()).To(Equal("AccessKeyID"))
+		})
+	})
+
+	Describe("A call which gets no response", func() {
+		JustBeforeEach(func() {
+			server.Close()
+			_, err = caller.Get(nil, "/vendors/v1", nil, true)
+		})
+
+		It("counts a timeout and a request without a status", func() {
+			Expect(err).NotTo(BeNil())
+			Expect(testutil.ToFloat64(sut.timeouts.WithLabelValues(els.RouteUnknown))).To(Equal(1.0))
+			Expect(testutil.ToFloat64(sut.requests.WithLabelValues(els.RouteUnknown, "GET", "none"))).To(Equal(1.0))
+		})
+	})
+
+	Describe("A call which can't be signed", func() {
+		BeforeEach(func() {
+			signer, err = els.NewAPISigner(&els.AccessKey{
+				ID:              "AccessKeyID",
+				SecretAccessKey: "secret",
+				ExpiryDate:      now.Add(-time.Hour),
+			})
+			Expect(err).To(BeNil())
+		})
+
+		JustBeforeEach(func() {
+			_, err = caller.Get(nil, "/vendors/v1", signer, true)
+		})
+
+		It("counts a signing failure by error type", func() {
+			Expect(err).To(Equal(els.ErrExpiredAccessKey))
+			Expect(testutil.ToFloat64(sut.signingFailures.WithLabelValues(els.RouteUnknown, els.SignErrExpiredAccessKey))).To(Equal(1.0))
+			Expect(testutil.CollectAndCount(sut.requests)).To(Equal(0))
+		})
+	})
+})
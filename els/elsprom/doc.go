@@ -0,0 +1,6 @@
+// Package elsprom provides a Prometheus implementation of els.MetricsCollector,
+// recording requests by route and status code, their latency, timeouts,
+// signing failures and the time until each access key in use expires.
+// Register the Collector returned by NewCollector with Prometheus and add it
+// with EDAPICaller.AddMetricsCollector.
+package elsprom
@@ -0,0 +1,14 @@
+package elsprom
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestElsprom(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "elsprom Suite")
+}
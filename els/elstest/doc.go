@@ -0,0 +1,17 @@
+/*
+Package elstest provides a fake ELS server for testing code which makes ELS API
+calls. The server verifies the ELS signature of each request it receives
+(including multipart uploads and other non-JSON content types), records the
+requests, and responds with the handlers registered for their routes:
+
+	s := elstest.NewServer(accessKey)
+	defer s.Close()
+	s.HandleFunc("GET", "/vendors/v1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"v1"}`))
+	})
+	caller := s.NewAPICaller(tp)
+
+Requests which are not correctly signed are rejected with 401 Unauthorized
+unless AllowUnsigned is set.
+*/
+package elstest
@@ -0,0 +1,14 @@
+package elstest
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestElstest(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "elstest Suite")
+}
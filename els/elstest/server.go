@@ -0,0 +1,195 @@
+package elstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elasticlic/els-api-sdk-go/els"
+	"github.com/elasticlic/go-utils/datetime"
+)
+
+// MaxMultipartMemory is the memory used to parse a multipart body by
+// Request.Multipart, beyond which files are stored on disk.
+var MaxMultipartMemory int64 = 10 << 20
+
+// ErrNotMultipart is returned by Request.Multipart if the request does not
+// have a multipart body.
+var ErrNotMultipart = errors.New("Not Multipart")
+
+// Request is a request received by a Server.
+type Request struct {
+	Method string
+
+	// Path is the path of the request, and Route the path relative to the
+	// API version (e.g. "/vendors/v1").
+	Path  string
+	Route string
+
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+
+	// AccessKeyID is the ID of the access key which signed the request, if
+	// its signature was verified.
+	AccessKeyID els.AccessKeyID
+
+	// Err is the error with which verification failed, or nil.
+	Err error
+}
+
+// Multipart parses the multipart/form-data body of the request.
+func (r Request) Multipart() (*multipart.Form, error) {
+	mt, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mt, "multipart/") {
+		return nil, ErrNotMultipart
+	}
+	return multipart.NewReader(bytes.NewReader(r.Body), params["boundary"]).ReadForm(MaxMultipartMemory)
+}
+
+// Server is a fake ELS. Create one with NewServer.
+type Server struct {
+	*httptest.Server
+
+	// Now returns the time at which signatures are verified. It is time.Now
+	// by default; set it to match the TimeProvider of the caller in tests
+	// which control the time.
+	Now func() time.Time
+
+	// AllowUnsigned passes requests whose signature could not be verified to
+	// the handlers rather than rejecting them. Their Err is still recorded.
+	AllowUnsigned bool
+
+	verifier *els.Verifier
+
+	mu       sync.Mutex
+	keys     map[els.AccessKeyID]*els.AccessKey
+	handlers map[string]http.Handler
+	requests []Request
+}
+
+// NewServer starts and returns a Server which accepts requests signed by the
+// given access keys. Close it when done.
+func NewServer(keys ...*els.AccessKey) *Server {
+	s := &Server{
+		Now:      time.Now,
+		keys:     map[els.AccessKeyID]*els.AccessKey{},
+		handlers: map[string]http.Handler{},
+	}
+	s.verifier = els.NewVerifier(els.KeyStoreFunc(s.accessKey))
+	for _, k := range keys {
+		s.AddKey(k)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// AddKey adds an access key which may sign requests.
+func (s *Server) AddKey(k *els.AccessKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[k.ID] = k
+}
+
+// accessKey implements els.KeyStore.
+func (s *Server) accessKey(id els.AccessKeyID) (*els.AccessKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, els.ErrUnknownAccessKey
+	}
+	return k, nil
+}
+
+// Handle registers the handler for requests with the given method and route
+// (relative to the API version, e.g. "/vendors/v1"). Requests for routes
+// without a handler get a 404 Not Found response.
+func (s *Server) Handle(method string, route string, h http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method+" "+route] = h
+}
+
+// HandleFunc registers a handler function as Handle does.
+func (s *Server) HandleFunc(method string, route string, f func(http.ResponseWriter, *http.Request)) {
+	s.Handle(method, route, http.HandlerFunc(f))
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// NewAPICaller returns an EDAPICaller which calls the server, using tp to
+// provide the signing time.
+func (s *Server) NewAPICaller(tp datetime.TimeProvider) *els.EDAPICaller {
+	a := els.NewEDAPICaller(s.Client(), tp, els.DefaultRequestTimeout, "")
+	u, _ := url.Parse(s.URL)
+	a.APIHandler.Scheme = u.Scheme
+	a.APIHandler.Domain = u.Host
+	return a
+}
+
+// serve verifies and records request r, then passes it to its handler.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	k, verr := s.verifier.Verify(r, s.Now())
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	route := r.URL.Path
+	if v := els.PathAPIVersion(route); v != "" {
+		route = strings.TrimPrefix(route, "/"+v)
+	}
+
+	rec := Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Route:  route,
+		Query:  r.URL.Query(),
+		Header: r.Header,
+		Body:   body,
+		Err:    verr,
+	}
+	if k != nil {
+		rec.AccessKeyID = k.ID
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, rec)
+	h := s.handlers[r.Method+" "+route]
+	s.mu.Unlock()
+
+	if verr != nil && !s.AllowUnsigned {
+		writeError(w, http.StatusUnauthorized, verr)
+		return
+	}
+	if h == nil {
+		writeError(w, http.StatusNotFound, errors.New("Not Found"))
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+// writeError responds with status and err as a JSON error.
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
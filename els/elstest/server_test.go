@@ -0,0 +1,135 @@
+package elstest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/elasticlic/els-api-sdk-go/els"
+	"github.com/elasticlic/go-utils/datetime"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server Test Suite", func() {
+
+	var (
+		now, _ = time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+		k      = &els.AccessKey{ID: "id", SecretAccessKey: "secret"}
+		sut    *Server
+		caller *els.EDAPICaller
+		signer *els.APISigner
+
+		upload = func() *http.Request {
+			r, err := els.NewMultipartRequest("POST", "/vendors/v1/files",
+				url.Values{"title": {"Licence"}},
+				els.FileFromBytes("file", "licence.bin", []byte{0, 1, 2, 3}))
+			Expect(err).To(BeNil())
+			return r
+		}
+	)
+
+	BeforeEach(func() {
+		sut = NewServer(k)
+		sut.Now = func() time.Time { return now }
+		sut.HandleFunc("POST", "/vendors/v1/files", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})
+
+		tp := datetime.NewNowTimeProvider()
+		tp.SetNow(now)
+		caller = sut.NewAPICaller(tp)
+
+		var err error
+		signer, err = els.NewAPISigner(k)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		sut.Close()
+	})
+
+	It("verifies a signed multipart upload", func() {
+		rep, err := caller.Do(nil, upload(), signer, true)
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(rep.StatusCode).To(Equal(http.StatusCreated))
+
+		reqs := sut.Requests()
+		Expect(reqs).To(HaveLen(1))
+		Expect(reqs[0].Err).To(BeNil())
+		Expect(reqs[0].AccessKeyID).To(Equal(k.ID))
+		Expect(reqs[0].Route).To(Equal("/vendors/v1/files"))
+		Expect(reqs[0].Header.Get("Content-Type")).To(HavePrefix("multipart/form-data; boundary="))
+
+		f, err := reqs[0].Multipart()
+		Expect(err).To(BeNil())
+		Expect(f.Value["title"]).To(Equal([]string{"Licence"}))
+		Expect(f.File["file"]).To(HaveLen(1))
+		Expect(f.File["file"][0].Filename).To(Equal("licence.bin"))
+		fr, err := f.File["file"][0].Open()
+		Expect(err).To(BeNil())
+		b, err := ioutil.ReadAll(fr)
+		Expect(err).To(BeNil())
+		Expect(b).To(Equal([]byte{0, 1, 2, 3}))
+	})
+
+	It("verifies a multipart upload signed with SchemeV2", func() {
+		s2, err := els.NewAPISignerV2(k)
+		Expect(err).To(BeNil())
+		rep, err := caller.Do(nil, upload(), s2, true)
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(rep.StatusCode).To(Equal(http.StatusCreated))
+	})
+
+	It("verifies a binary upload with its own content type", func() {
+		sut.HandleFunc("PUT", "/vendors/v1/image", func(w http.ResponseWriter, r *http.Request) {})
+		r, err := http.NewRequest("PUT", "/vendors/v1/image", bytes.NewReader([]byte{9, 9}))
+		Expect(err).To(BeNil())
+		r.Header.Set("Content-Type", "image/png")
+
+		rep, err := caller.Do(nil, r, signer, true)
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(rep.StatusCode).To(Equal(http.StatusOK))
+		Expect(sut.Requests()[0].Header.Get("Content-Type")).To(Equal("image/png"))
+	})
+
+	It("rejects a request whose content type was changed after signing", func() {
+		caller.Use(els.StageAfterSign, els.InterceptorFunc(func(ctx context.Context, e *els.Exchange) error {
+			e.Request.Header.Set("Content-Type", "application/octet-stream")
+			return nil
+		}))
+		rep, err := caller.Do(nil, upload(), signer, true)
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(rep.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(sut.Requests()[0].Err).To(Equal(els.ErrSignatureMismatch))
+	})
+
+	It("rejects unsigned requests unless allowed", func() {
+		rep, err := caller.Do(nil, upload(), nil, true)
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(rep.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(sut.Requests()[0].Err).To(Equal(els.ErrNoSignature))
+
+		sut.AllowUnsigned = true
+		rep, err = caller.Do(nil, upload(), nil, true)
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(rep.StatusCode).To(Equal(http.StatusCreated))
+	})
+
+	It("responds 404 for routes without a handler", func() {
+		rep, err := caller.Get(nil, "/vendors/v2", signer, true)
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(rep.StatusCode).To(Equal(http.StatusNotFound))
+	})
+})
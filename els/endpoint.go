@@ -0,0 +1,210 @@
+package els
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// DefaultEndpointCooldown is how long an endpoint is considered unhealthy
+// after a failure if SetEndpoints is passed a cooldown of 0.
+const DefaultEndpointCooldown = 30 * time.Second
+
+// Endpoint is an ELS endpoint to which ELS API calls may be sent.
+type Endpoint struct {
+	// Name identifies the endpoint, e.g. "primary" or "eu-west".
+	Name string
+
+	// Scheme and Domain replace those of the APIHandler in the URLs of calls
+	// sent to the endpoint.
+	Scheme string
+	Domain string
+}
+
+// EndpointStatus describes the health of an Endpoint.
+type EndpointStatus struct {
+	Endpoint
+
+	// Healthy is false if the endpoint failed within the cooldown period.
+	Healthy bool
+
+	// Current is true if calls are currently sent to the endpoint first.
+	Current bool
+
+	// LastTimeout is when a call to the endpoint last failed to get a
+	// response, and LastFailure when a call to it last failed in any way
+	// (including a 5xx response).
+	LastTimeout time.Time
+	LastFailure time.Time
+
+	// ConsecutiveFailures is the number of calls to the endpoint which have
+	// failed since one last succeeded.
+	ConsecutiveFailures int
+}
+
+// endpointSet holds the endpoints set with SetEndpoints and their status.
+type endpointSet struct {
+	sync.Mutex
+	statuses []EndpointStatus
+	current  int
+	cooldown time.Duration
+}
+
+// SetEndpoints sets an ordered list of endpoints (e.g. primary, secondary,
+// regional) to which ELS API calls are sent, replacing the Scheme and Domain of
+// the APIHandler (CreateAccessKey still uses those). Pass no endpoints to
+// revert to the APIHandler.
+//
+// Calls are sent to the current endpoint - initially the first - which is
+// sticky: it remains current until a call to it fails to get a response or
+// gets a 5xx response. The call then fails over to the other endpoints in
+// order, preferring those which haven't failed within the cooldown period, and
+// the first to succeed becomes current. Failover requires the request body to
+// be resendable (see Do).
+func (a *EDAPICaller) SetEndpoints(cooldown time.Duration, eps ...Endpoint) {
+	a.Lock()
+	defer a.Unlock()
+
+	if len(eps) == 0 {
+		a.endpoints = nil
+		return
+	}
+
+	if cooldown == 0 {
+		cooldown = DefaultEndpointCooldown
+	}
+	es := &endpointSet{cooldown: cooldown}
+	for _, ep := range eps {
+		es.statuses = append(es.statuses, EndpointStatus{Endpoint: ep})
+	}
+	a.endpoints = es
+}
+
+// Endpoints returns the status of the endpoints set with SetEndpoints.
+func (a *EDAPICaller) Endpoints() []EndpointStatus {
+	es := a.getEndpoints()
+	if es == nil {
+		return nil
+	}
+
+	now := a.tp.Now()
+	es.Lock()
+	defer es.Unlock()
+	ss := make([]EndpointStatus, len(es.statuses))
+	for i, s := range es.statuses {
+		s.Healthy = es.healthy(i, now)
+		s.Current = i == es.current
+		ss[i] = s
+	}
+	return ss
+}
+
+// getEndpoints returns the endpoints set with SetEndpoints, or nil if none
+// are set.
+func (a *EDAPICaller) getEndpoints() *endpointSet {
+	a.RLock()
+	defer a.RUnlock()
+	return a.endpoints
+}
+
+// failover sends request r for exchange e to each endpoint in turn, in the
+// order returned by endpointSet.order, until one succeeds. Only failures to
+// get a response and 5xx responses count against an endpoint: other errors,
+// and the cancellation or timeout of ctx, are returned at once.
+func (a *EDAPICaller) failover(ctx context.Context, es *endpointSet, e *Exchange, r *http.Request) (resp *http.Response, err error) {
+	order := es.order(a.tp.Now())
+	for n, i := range order {
+		ep := es.endpoint(i)
+		if n > 0 {
+			log.WithFields(log.Fields{"Time": time.Now(), "endpoint": ep.Name}).Debug("ApiCaller: Failing over")
+			if err = resetBody(r); err != nil {
+				return nil, err
+			}
+		}
+
+		r.URL.Scheme = ep.Scheme
+		r.URL.Host = ep.Domain
+		r.Host = ""
+
+		resp, err = a.send(ctx, e, r)
+		if err != nil && (!e.sendFailed || ctx.Err() != nil) {
+			// The endpoint is not at fault: the request wasn't sent, an
+			// interceptor failed or the call was cancelled or timed out.
+			return resp, err
+		}
+		failed := err != nil || resp.StatusCode >= 500
+		es.record(i, a.tp.Now(), err != nil, failed)
+
+		if !failed || n == len(order)-1 || ctx.Err() != nil || !canResend(r) {
+			break
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}
+
+	return resp, err
+}
+
+// endpoint returns the ith endpoint.
+func (es *endpointSet) endpoint(i int) Endpoint {
+	es.Lock()
+	defer es.Unlock()
+	return es.statuses[i].Endpoint
+}
+
+// healthy returns true if the ith endpoint has not failed within the cooldown
+// period before now. es must be locked.
+func (es *endpointSet) healthy(i int, now time.Time) bool {
+	s := es.statuses[i]
+	return s.ConsecutiveFailures == 0 || now.Sub(s.LastFailure) >= es.cooldown
+}
+
+// order returns the indexes of the endpoints in the order they should be tried
+// at time now: the current endpoint if healthy, then the other healthy
+// endpoints, then the unhealthy ones.
+func (es *endpointSet) order(now time.Time) []int {
+	es.Lock()
+	defer es.Unlock()
+
+	var healthy, unhealthy []int
+	currentHealthy := es.healthy(es.current, now)
+	if currentHealthy {
+		healthy = append(healthy, es.current)
+	}
+	for i := range es.statuses {
+		if i == es.current && currentHealthy {
+			continue
+		}
+		if es.healthy(i, now) {
+			healthy = append(healthy, i)
+		} else {
+			unhealthy = append(unhealthy, i)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// record records the outcome at time now of a call to the ith endpoint, which
+// failed if failed is true, having timed-out if timedOut is true. An endpoint
+// which succeeds becomes current.
+func (es *endpointSet) record(i int, now time.Time, timedOut bool, failed bool) {
+	es.Lock()
+	defer es.Unlock()
+
+	s := &es.statuses[i]
+	if !failed {
+		s.ConsecutiveFailures = 0
+		es.current = i
+		return
+	}
+
+	s.ConsecutiveFailures++
+	s.LastFailure = now
+	if timedOut {
+		s.LastTimeout = now
+	}
+}
@@ -0,0 +1,178 @@
+package els
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Endpoint Test Suite", func() {
+
+	var (
+		now, _    = time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+		tp        *datetime.NowTimeProvider
+		sut       *EDAPICaller
+		primary   *httptest.Server
+		secondary *httptest.Server
+		status    int
+		eps       []Endpoint
+		auths     [][]string
+
+		// newServer returns a server which responds with its name, and with
+		// status if it is the primary.
+		newServer = func(name string) *httptest.Server {
+			return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auths = append(auths, r.Header["Authorization"])
+				if name == "primary" {
+					w.WriteHeader(status)
+				}
+				w.Write([]byte(name))
+			}))
+		}
+
+		endpoint = func(name string, s *httptest.Server) Endpoint {
+			u, err := url.Parse(s.URL)
+			Expect(err).To(BeNil())
+			return Endpoint{Name: name, Scheme: u.Scheme, Domain: u.Host}
+		}
+
+		// get makes a call and returns the body of the response.
+		get = func() string {
+			rep, err := sut.Get(nil, "/vendors/v1", nil, true)
+			Expect(err).To(BeNil())
+			defer rep.Body.Close()
+			b, err := ioutil.ReadAll(rep.Body)
+			Expect(err).To(BeNil())
+			return string(b)
+		}
+	)
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		auths = nil
+		primary = newServer("primary")
+		secondary = newServer("secondary")
+
+		tp = datetime.NewNowTimeProvider()
+		tp.SetNow(now)
+		sut = NewEDAPICaller(&http.Client{}, tp, time.Second, "")
+		eps = []Endpoint{endpoint("primary", primary), endpoint("secondary", secondary)}
+	})
+
+	JustBeforeEach(func() {
+		sut.SetEndpoints(time.Minute, eps...)
+	})
+
+	AfterEach(func() {
+		primary.Close()
+		secondary.Close()
+	})
+
+	It("sends calls to the first endpoint", func() {
+		Expect(get()).To(Equal("primary"))
+		ss := sut.Endpoints()
+		Expect(ss[0].Current).To(BeTrue())
+		Expect(ss[0].Healthy).To(BeTrue())
+	})
+
+	Context("The primary returns 5xx", func() {
+		BeforeEach(func() {
+			status = http.StatusServiceUnavailable
+		})
+		It("fails over to the secondary and sticks to it", func() {
+			Expect(get()).To(Equal("secondary"))
+			ss := sut.Endpoints()
+			Expect(ss[0].Healthy).To(BeFalse())
+			Expect(ss[0].ConsecutiveFailures).To(Equal(1))
+			Expect(ss[0].LastTimeout.IsZero()).To(BeTrue())
+			Expect(ss[1].Current).To(BeTrue())
+
+			status = http.StatusOK
+			tp.SetNow(now.Add(2 * time.Minute))
+			Expect(get()).To(Equal("secondary"))
+		})
+
+		It("signs the request afresh for the secondary", func() {
+			rep, err := sut.Get(nil, "/vendors/v1", &DummySigner{}, true)
+			Expect(err).To(BeNil())
+			rep.Body.Close()
+			Expect(auths).To(Equal([][]string{{"some auth"}, {"some auth"}}))
+		})
+	})
+
+	Context("The primary is unreachable", func() {
+		BeforeEach(func() {
+			primary.Close()
+		})
+		It("fails over and records the endpoint's timeout", func() {
+			Expect(get()).To(Equal("secondary"))
+			Expect(sut.Endpoints()[0].LastTimeout).To(Equal(now))
+		})
+	})
+
+	Context("The call fails for reasons other than the endpoint", func() {
+		It("doesn't fail over when the call is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := sut.Get(ctx, "/vendors/v1", nil, true)
+			Expect(err).NotTo(BeNil())
+			Expect(auths).To(BeEmpty())
+			Expect(sut.Endpoints()[0].ConsecutiveFailures).To(Equal(0))
+		})
+
+		It("doesn't fail over when an interceptor fails", func() {
+			boom := errors.New("boom")
+			for _, st := range []Stage{StageAfterSign, StageAfterResponse} {
+				st := st
+				sut.Use(st, InterceptorFunc(func(ctx context.Context, e *Exchange) error {
+					if st == StageAfterResponse || e.Request.URL.Path == "/1.0/vendors/v2" {
+						return boom
+					}
+					return nil
+				}))
+			}
+
+			_, err := sut.Get(nil, "/vendors/v2", nil, true)
+			Expect(err).To(Equal(boom))
+			_, err = sut.Get(nil, "/vendors/v1", nil, true)
+			Expect(err).To(Equal(boom))
+			Expect(auths).To(HaveLen(1))
+			Expect(sut.Endpoints()[0].ConsecutiveFailures).To(Equal(0))
+			Expect(sut.Endpoints()[0].Current).To(BeTrue())
+		})
+	})
+
+	Context("The current endpoint fails", func() {
+		It("fails back to a healthy endpoint in order", func() {
+			status = http.StatusServiceUnavailable
+			get()
+			status = http.StatusOK
+			secondary.Close()
+			tp.SetNow(now.Add(2 * time.Minute))
+			Expect(get()).To(Equal("primary"))
+			Expect(sut.Endpoints()[0].Current).To(BeTrue())
+		})
+	})
+
+	Context("All endpoints fail", func() {
+		BeforeEach(func() {
+			status = http.StatusServiceUnavailable
+			eps = eps[:1]
+		})
+		It("returns the last response", func() {
+			rep, err := sut.Get(nil, "/vendors/v1", nil, true)
+			Expect(err).To(BeNil())
+			rep.Body.Close()
+			Expect(rep.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		})
+	})
+})
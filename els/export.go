@@ -0,0 +1,438 @@
+package els
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrBinaryBody is returned by CurlCommand, HTTPieCommand and NewHAREntry if
+// the body of the request is not text, so can't be included in the export.
+var ErrBinaryBody = errors.New("Binary Body")
+
+// redacted replaces secrets in redacted exports.
+const redacted = "REDACTED"
+
+// secretHeaders are the headers whose values are redacted.
+var secretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", DefaultAPIKeyHeader}
+
+// ExportOption sets an option of CurlCommand, HTTPieCommand or NewHAREntry.
+type ExportOption func(o *exportOptions)
+
+// SecretInfo is implemented by signers which put secrets in headers other
+// than Authorization, or in query parameters, so that Redacted can find them.
+type SecretInfo interface {
+	// SecretHeaders returns the names of the headers in which the signer puts
+	// secrets.
+	SecretHeaders() []string
+
+	// SecretQueryParams returns the names of the query parameters in which the
+	// signer puts secrets.
+	SecretQueryParams() []string
+}
+
+// exportOptions holds the settings of an export.
+type exportOptions struct {
+	redact bool
+
+	// headers and params are the headers and query parameters whose values
+	// are redacted entirely.
+	headers []string
+	params  []string
+}
+
+// Redacted replaces the secrets in an exported request with "REDACTED": the
+// signature (keeping the access key ID), other credentials in the
+// Authorization, Proxy-Authorization, Cookie and X-Api-Key headers, the
+// signature of a presigned URL and the password in the URL. Pass the signers
+// which signed the request so that the headers and query parameters in which
+// they put secrets are redacted too, if they implement SecretInfo (as
+// APIKeyAuth does). The export then can't be run as it is, but can be shared,
+// e.g. in a support ticket.
+func Redacted(signers ...Signer) ExportOption {
+	return func(o *exportOptions) {
+		o.redact = true
+		for _, s := range signers {
+			if si, ok := s.(SecretInfo); ok {
+				o.headers = append(o.headers, si.SecretHeaders()...)
+				o.params = append(o.params, si.SecretQueryParams()...)
+			}
+		}
+	}
+}
+
+// exportedRequest is a request as it is exported: its URL, its headers sorted
+// by name and its body.
+type exportedRequest struct {
+	method  string
+	url     string
+	headers [][2]string
+	body    []byte
+}
+
+// exportRequest returns r as it is to be exported with opts. The body of r is
+// read, and replaced so that it can be sent afterwards.
+func exportRequest(r *http.Request, opts []ExportOption) (*exportedRequest, error) {
+	if r == nil {
+		return nil, ErrNoRequest
+	}
+	o := exportOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	body, err := requestBody(r)
+	if err != nil {
+		return nil, err
+	}
+	if !isText(body) {
+		return nil, ErrBinaryBody
+	}
+
+	u := *r.URL
+	h := r.Header.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	if r.Host != "" && r.Host != u.Host {
+		h.Set("Host", r.Host)
+	}
+	h.Del("Content-Length")
+
+	if o.redact {
+		redactURL(&u, o.params)
+		for _, k := range secretHeaders {
+			for i, v := range h[k] {
+				h[k][i] = redactCredentials(v)
+			}
+		}
+		for _, k := range o.headers {
+			k = http.CanonicalHeaderKey(k)
+			for i := range h[k] {
+				h[k][i] = redacted
+			}
+		}
+	}
+
+	e := &exportedRequest{method: r.Method, url: u.String(), body: body}
+	if e.method == "" {
+		e.method = "GET"
+	}
+
+	names := make([]string, 0, len(h))
+	for k := range h {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		for _, v := range h[k] {
+			e.headers = append(e.headers, [2]string{k, v})
+		}
+	}
+
+	return e, nil
+}
+
+// requestBody returns the body of r without consuming it: it is read from
+// GetBody if set, otherwise it is read and replaced.
+func requestBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if r.GetBody != nil {
+		rc, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return ioutil.ReadAll(rc)
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+	return b, nil
+}
+
+// isText returns true if b is UTF-8 text without control characters other
+// than whitespace.
+func isText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, c := range b {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// redactURL redacts the password, the presigned signature and the values of
+// the query parameters params in u.
+func redactURL(u *url.URL, params []string) {
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+	}
+
+	q := u.Query()
+	changed := false
+	for _, p := range append([]string{QuerySignature}, params...) {
+		if q.Get(p) != "" {
+			q.Set(p, redacted)
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = q.Encode()
+	}
+}
+
+// redactCredentials redacts the credentials in the value v of an
+// Authorization or similar header. The access key ID of an ELS signature is
+// kept, as it identifies the signer without being secret.
+func redactCredentials(v string) string {
+	i := strings.Index(v, " ")
+	if i < 0 {
+		return redacted
+	}
+	scheme, creds := v[:i+1], v[i+1:]
+
+	if scheme == "ELS " || scheme == v2AuthPrefix {
+		if j := strings.LastIndex(creds, ":"); j >= 0 {
+			return scheme + creds[:j+1] + redacted
+		}
+	}
+	return scheme + redacted
+}
+
+// shellQuote quotes s as a single word for a POSIX shell.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.,/:@%+") == "" {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// commandSeparator separates the arguments of exported commands, one per line.
+const commandSeparator = " \\\n  "
+
+// CurlCommand returns a curl command line which sends request r, with its
+// headers and body, e.g. a request returned by EDAPICaller.DryRun. The body of
+// r is not consumed. The command is for a POSIX shell.
+func CurlCommand(r *http.Request, opts ...ExportOption) (string, error) {
+	e, err := exportRequest(r, opts)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := "curl "
+	switch e.method {
+	case "GET":
+	case "HEAD":
+		cmd += "--head "
+	default:
+		cmd += "-X " + shellQuote(e.method) + " "
+	}
+	args := []string{cmd + shellQuote(e.url)}
+
+	for _, h := range e.headers {
+		if h[1] == "" {
+			// curl removes headers given without a value.
+			args = append(args, "-H "+shellQuote(h[0]+";"))
+		} else {
+			args = append(args, "-H "+shellQuote(h[0]+": "+h[1]))
+		}
+	}
+	if e.body != nil {
+		args = append(args, "--data-binary "+shellQuote(string(e.body)))
+	}
+
+	return strings.Join(args, commandSeparator), nil
+}
+
+// HTTPieCommand returns an HTTPie (version 2.3 or later) command line which
+// sends request r as CurlCommand does.
+func HTTPieCommand(r *http.Request, opts ...ExportOption) (string, error) {
+	e, err := exportRequest(r, opts)
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"http --ignore-stdin " + shellQuote(e.method) + " " + shellQuote(e.url)}
+	for _, h := range e.headers {
+		if h[1] == "" {
+			args = append(args, shellQuote(h[0]+";"))
+		} else {
+			args = append(args, shellQuote(h[0]+":"+h[1]))
+		}
+	}
+	if e.body != nil {
+		args = append(args, "--raw "+shellQuote(string(e.body)))
+	}
+
+	return strings.Join(args, commandSeparator), nil
+}
+
+// HAR is an HTTP Archive (HAR 1.2), which can be imported into browsers and
+// other tools.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the log of a HAR.
+type HARLog struct {
+	Version string      `json:"version"`
+	Creator HARCreator  `json:"creator"`
+	Entries []*HAREntry `json:"entries"`
+}
+
+// HARCreator identifies the creator of a HAR.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is an entry of a HAR: a request and its response.
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+}
+
+// HARRequest is a request in a HAR.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse is a response in a HAR.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue is a header, cookie or query parameter in a HAR.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is the body of a request in a HAR.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARContent is the body of a response in a HAR.
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+// HARTimings are the timings of an entry in a HAR, in milliseconds.
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// NewHAR returns a HAR holding the given entries.
+func NewHAR(entries ...*HAREntry) *HAR {
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "els-api-sdk-go"},
+		Entries: entries,
+	}}
+}
+
+// NewHAREntry returns a HAR entry for request r, as CurlCommand does, with an
+// empty response (status 0) as it has not been sent. The entry is started at
+// the time the request was signed, if it has been.
+func NewHAREntry(r *http.Request, opts ...ExportOption) (*HAREntry, error) {
+	e, err := exportRequest(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	if t, err := time.Parse(time.RFC3339, r.Header.Get("X-Els-Date")); err == nil {
+		started = t
+	}
+
+	req := HARRequest{
+		Method:      e.method,
+		URL:         e.url,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []HARNameValue{},
+		Headers:     []HARNameValue{},
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(e.body),
+	}
+	for _, h := range e.headers {
+		req.Headers = append(req.Headers, HARNameValue{Name: h[0], Value: h[1]})
+	}
+
+	u, _ := url.Parse(e.url)
+	q := u.Query()
+	names := make([]string, 0, len(q))
+	for k := range q {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		for _, v := range q[k] {
+			req.QueryString = append(req.QueryString, HARNameValue{Name: k, Value: v})
+		}
+	}
+
+	if e.body != nil {
+		req.PostData = &HARPostData{MimeType: r.Header.Get("Content-Type"), Text: string(e.body)}
+	}
+
+	return &HAREntry{
+		StartedDateTime: started.UTC().Format(time.RFC3339Nano),
+		Request:         req,
+		Response: HARResponse{
+			Cookies:     []HARNameValue{},
+			Headers:     []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+	}, nil
+}
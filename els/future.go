@@ -0,0 +1,158 @@
+package els
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+
+	"golang.org/x/net/context"
+)
+
+// Future is the eventual result of an API call made asynchronously with
+// EDAPICaller.DoAsync or GetAsync.
+type Future struct {
+	done   chan struct{}
+	resp   *http.Response
+	err    error
+	cancel func()
+}
+
+// DoAsync starts Do in a new goroutine and returns a Future for its result.
+// The arguments are as for Do; in particular a nil ctx applies the caller's
+// default timeout. The call may be cancelled with Future.Cancel. The context
+// of the call lasts until the body of the response is closed, so close it even
+// if it isn't read.
+func (a *EDAPICaller) DoAsync(ctx context.Context, r *http.Request, s Signer, isELSAPI bool) *Future {
+	return a.async(ctx, r, s, isELSAPI, OpDo)
+}
+
+// GetAsync starts Get in a new goroutine and returns a Future for its result,
+// as DoAsync does.
+func (a *EDAPICaller) GetAsync(ctx context.Context, url string, s Signer, isELSAPI bool) *Future {
+	r, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		f := &Future{done: make(chan struct{}), err: err, cancel: func() {}}
+		close(f.done)
+		return f
+	}
+	return a.async(ctx, r, s, isELSAPI, OpGet)
+}
+
+// async makes the call with request r in a new goroutine, reporting it to
+// observers as operation op.
+func (a *EDAPICaller) async(ctx context.Context, r *http.Request, s Signer, isELSAPI bool, op string) *Future {
+	var cancel func()
+	if ctx == nil {
+		ctx, cancel = context.WithTimeout(context.Background(), a.requestTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	f := &Future{done: make(chan struct{}), cancel: cancel}
+
+	go func() {
+		defer close(f.done)
+		f.resp, f.err = a.doWith(ctx, r, NewCallOptions(legacyOptions(s, isELSAPI)...), op)
+		if f.err != nil {
+			cancel()
+			return
+		}
+		// Release the context once the caller has finished with the body.
+		f.resp.Body = &cancelOnClose{ReadCloser: f.resp.Body, cancel: cancel}
+	}()
+
+	return f
+}
+
+// cancelOnClose cancels the context of a call when the body of its response
+// is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// Done returns a channel which is closed when the call completes, for use in a
+// select statement.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result returns the result of the call, which must have completed (see
+// Done).
+func (f *Future) Result() (*http.Response, error) {
+	<-f.done
+	return f.resp, f.err
+}
+
+// Wait waits for the call to complete and returns its result, or returns
+// ctx.Err() if ctx is done first (without cancelling the call). Pass nil as
+// ctx to wait indefinitely.
+func (f *Future) Wait(ctx context.Context) (*http.Response, error) {
+	if ctx == nil {
+		return f.Result()
+	}
+	select {
+	case <-f.done:
+		return f.resp, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Cancel cancels the call. If it has already completed, the body of its
+// response can no longer be read.
+func (f *Future) Cancel() {
+	f.cancel()
+}
+
+// WaitAll waits for all the calls to complete and returns the first error (in
+// the order given) of any of them, or ctx.Err() if ctx is done first. Pass nil
+// as ctx to wait indefinitely.
+func WaitAll(ctx context.Context, fs ...*Future) error {
+	for _, f := range fs {
+		if ctx == nil {
+			<-f.done
+			continue
+		}
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	for _, f := range fs {
+		if f.err != nil {
+			return f.err
+		}
+	}
+	return nil
+}
+
+// WaitAny waits for any of the calls to complete and returns its index, or -1
+// and ctx.Err() if ctx is done first. Pass nil as ctx to wait indefinitely. It
+// returns -1 immediately if there are no calls.
+func WaitAny(ctx context.Context, fs ...*Future) (int, error) {
+	if len(fs) == 0 {
+		return -1, nil
+	}
+
+	cases := make([]reflect.SelectCase, len(fs), len(fs)+1)
+	for i, f := range fs {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(f.done)}
+	}
+	if ctx != nil {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+	}
+
+	i, _, _ := reflect.Select(cases)
+	if i == len(fs) {
+		return -1, ctx.Err()
+	}
+	return i, nil
+}
@@ -0,0 +1,114 @@
+package els
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Future Test Suite", func() {
+
+	var (
+		sut    *EDAPICaller
+		server *httptest.Server
+		signer *DummySigner
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/1.0/slow" {
+				select {
+				case <-time.After(time.Second):
+				case <-r.Context().Done():
+					return
+				}
+			}
+			w.Write([]byte(r.URL.Path))
+		}))
+		u, perr := url.Parse(server.URL)
+		Expect(perr).To(BeNil())
+
+		sut = NewEDAPICaller(&http.Client{}, datetime.NewNowTimeProvider(), 5*time.Second, "")
+		sut.APIHandler.Scheme = u.Scheme
+		sut.APIHandler.Domain = u.Host
+		signer = &DummySigner{}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("returns the result of the call once done", func() {
+		f := sut.GetAsync(nil, "/fast", signer, true)
+		Eventually(f.Done()).Should(BeClosed())
+		rep, err := f.Result()
+		Expect(err).To(BeNil())
+		defer rep.Body.Close()
+		b, err := ioutil.ReadAll(rep.Body)
+		Expect(err).To(BeNil())
+		Expect(string(b)).To(Equal("/1.0/fast"))
+		Expect(signer.LastRequest).NotTo(BeNil())
+	})
+
+	It("can be cancelled", func() {
+		f := sut.GetAsync(nil, "/slow", nil, true)
+		f.Cancel()
+		_, err := f.Wait(nil)
+		Expect(err).To(Equal(context.Canceled))
+	})
+
+	It("stops waiting when the context of Wait is done", func() {
+		f := sut.GetAsync(nil, "/slow", nil, true)
+		defer f.Cancel()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := f.Wait(ctx)
+		Expect(err).To(Equal(context.DeadlineExceeded))
+	})
+
+	Describe("WaitAny", func() {
+		It("returns the index of the first call to complete", func() {
+			slow := sut.GetAsync(nil, "/slow", nil, true)
+			defer slow.Cancel()
+			fast := sut.GetAsync(nil, "/fast", nil, true)
+			i, err := WaitAny(nil, slow, fast)
+			Expect(err).To(BeNil())
+			Expect(i).To(Equal(1))
+			rep, _ := fast.Result()
+			rep.Body.Close()
+		})
+	})
+
+	Describe("WaitAll", func() {
+		It("waits for all the calls and reports the first error", func() {
+			fs := []*Future{
+				sut.GetAsync(nil, "/a", nil, true),
+				sut.GetAsync(nil, "::", nil, true),
+				sut.GetAsync(nil, "/b", nil, true),
+			}
+			err := WaitAll(nil, fs...)
+			Expect(err).NotTo(BeNil())
+			for _, i := range []int{0, 2} {
+				rep, rerr := fs[i].Result()
+				Expect(rerr).To(BeNil())
+				rep.Body.Close()
+			}
+		})
+
+		It("returns the context's error if it is done first", func() {
+			f := sut.GetAsync(nil, "/slow", nil, true)
+			defer f.Cancel()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			Expect(WaitAll(ctx, f)).To(Equal(context.DeadlineExceeded))
+		})
+	})
+})
@@ -7,7 +7,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
-	"strconv"
+	"net/url"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -62,15 +62,32 @@ func NewAPIHandler(c *http.Client) *APIHandler {
 // cancelled or times out then ctx.Err() will be returned. If there is a
 // response from the server but the http status code is not 201 (created), then
 // an error will be returned and statusCode will indicate the statuscode received.
+// To retry the call safely, pass a context carrying an idempotency key (see
+// IdempotencyKeyContext).
 func (h *APIHandler) CreateAccessKey(ctx context.Context, emailAddress string, password string, pwPrehashed bool, expiryDays uint) (a *AccessKey, statusCode int, err error) {
+	return h.createAccessKey(ctx, emailAddress, password, pwPrehashed, expiryDays, nil)
+}
+
+// accessKeyQuery holds the query parameters of a request to create an access
+// key.
+type accessKeyQuery struct {
+	Expires           bool `url:"expires,omitempty,int"`
+	NumDaysTillExpiry uint `url:"numDaysTillExpiry,omitempty"`
+}
 
-	url := h.urlPrefix() + "/users/" + emailAddress + "/accessKeys"
+// createAccessKey implements CreateAccessKey. If prepare is not nil, it is
+// called with the request before it is sent.
+func (h *APIHandler) createAccessKey(ctx context.Context, emailAddress string, password string, pwPrehashed bool, expiryDays uint, prepare func(*http.Request)) (a *AccessKey, statusCode int, err error) {
 
-	if expiryDays != 0 {
-		url = url + "?expires=1&numDaysTillExpiry=" + strconv.Itoa(int(expiryDays))
+	route, err := NewRoute("/users/{email}/accessKeys").
+		Set("email", emailAddress).
+		Query(accessKeyQuery{Expires: expiryDays != 0, NumDaysTillExpiry: expiryDays}).
+		Build()
+	if err != nil {
+		return nil, 0, err
 	}
 
-	req, err := http.NewRequest("POST", url, nil)
+	req, err := http.NewRequest("POST", h.urlPrefix()+route, nil)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -83,13 +100,21 @@ func (h *APIHandler) CreateAccessKey(ctx context.Context, emailAddress string, p
 	}
 
 	req.SetBasicAuth(emailAddress, password)
+	if key := IdempotencyKeyFromContext(ctx); key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+
+	if prepare != nil {
+		prepare(req)
+	}
 
 	log.WithFields(log.Fields{
-		"Time":     time.Now(),
-		"email":    emailAddress,
-		"password": password,
-		"auth":     req.Header["Authorization"],
-		"req":      req,
+		"Time":           time.Now(),
+		"email":          emailAddress,
+		"password":       password,
+		"auth":           req.Header["Authorization"],
+		"idempotencyKey": req.Header.Get(HeaderIdempotencyKey),
+		"req":            req,
 	}).Debug("APIHandler: CreateAccessKey")
 
 	rep, err := ctxhttp.Do(ctx, h.Client, req)
@@ -116,6 +141,18 @@ func (h *APIHandler) CreateAccessKey(ctx context.Context, emailAddress string, p
 	return k, rep.StatusCode, nil
 }
 
+// CompleteURL modifies u, which should hold the route of an ELS API call
+// relative to the API version (e.g. "/users/a@b.com/accessKeys"), so that it
+// refers to the route on the API endpoint configured in the handler.
+func (h *APIHandler) CompleteURL(u *url.URL) {
+	u.Scheme = h.Scheme
+	u.Host = h.Domain
+	u.Path = "/" + h.Version + u.Path
+	if u.RawPath != "" {
+		u.RawPath = "/" + h.Version + u.RawPath
+	}
+}
+
 // urlPrefix returns the string to prepend to each relative API url.
 func (h *APIHandler) urlPrefix() string {
 	return h.Scheme + "://" + h.Domain + "/" + h.Version
@@ -133,6 +133,32 @@ var _ = Describe("APIHandler Test Suite", func() {
 				})
 			})
 
+			Context("The email address contains reserved characters", func() {
+				BeforeEach(func() {
+					email = "a+b/c@test.com"
+				})
+				AfterEach(func() {
+					email = "example@test.com"
+				})
+				It("escapes it in the path", func() {
+					u := reqRec.URL
+					Expect(u.Path).To(Equal("/1.0/users/" + email + "/accessKeys"))
+					Expect(u.EscapedPath()).To(Equal("/1.0/users/a%2Bb%2Fc@test.com/accessKeys"))
+				})
+			})
+
+			Context("The access key does not expire", func() {
+				BeforeEach(func() {
+					expDays = 0
+				})
+				AfterEach(func() {
+					expDays = 3
+				})
+				It("omits the query", func() {
+					Expect(reqRec.URL.RawQuery).To(Equal(""))
+				})
+			})
+
 			Context("The ELS Returns an Access Key", func() {
 				JustBeforeEach(func() {
 					server, sut = simServer(201,
@@ -0,0 +1,115 @@
+package els
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// idempotencyKeyHeader is the lower-case name of HeaderIdempotencyKey, as
+// listed in the signed headers of SchemeV2.
+const idempotencyKeyHeader = "idempotency-key"
+
+// NewIdempotencyKey returns a new random idempotency key (a version 4 UUID).
+func NewIdempotencyKey() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	h := hex.EncodeToString(b[:])
+	return strings.Join([]string{h[0:8], h[8:12], h[12:16], h[16:20], h[20:]}, "-")
+}
+
+// idempotencyKeyKey is the context key under which IdempotencyKeyContext
+// stores an idempotency key.
+type idempotencyKeyKey struct{}
+
+// IdempotencyKeyContext returns a copy of ctx which gives the idempotency key
+// of a call to CreateAccessKey made with it, so that the call can be retried
+// as the same logical call. Use a new key for each logical call. Other calls
+// take their key from WithIdempotencyKey.
+func IdempotencyKeyContext(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the idempotency key set with
+// IdempotencyKeyContext, or "" if none was set.
+func IdempotencyKeyFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	k, _ := ctx.Value(idempotencyKeyKey{}).(string)
+	return k
+}
+
+// SetIdempotencyKeys determines whether an Idempotency-Key header is added to
+// the requests of ELS API calls which use non-idempotent methods (POST and
+// PATCH) and don't already have one, so that the server can recognise retries
+// of the same call and not act on it twice. A key is generated for each call
+// to Do, DoWith or CreateAccessKey (unless given with IdempotencyKeyContext),
+// and stays the same for all of the call's retries. It is reported in
+// Call.IdempotencyKey. The key is signed by SchemeV2 only: SchemeV1 signs a
+// fixed set of headers which doesn't include it, so under SchemeV1 the key is
+// not protected from being changed in transit. It is on by default.
+func (a *EDAPICaller) SetIdempotencyKeys(on bool) {
+	a.Lock()
+	defer a.Unlock()
+	a.noIdempotencyKeys = !on
+}
+
+// setIdempotencyKey adds an idempotency key to request r of a call to the ELS
+// API if enabled and required: key if given, otherwise a new one.
+func (a *EDAPICaller) setIdempotencyKey(r *http.Request, isELSAPI bool, key string) {
+	a.RLock()
+	off := a.noIdempotencyKeys
+	a.RUnlock()
+
+	if off || !isELSAPI || !needsIdempotencyKey(r.Method) || r.Header.Get(HeaderIdempotencyKey) != "" {
+		return
+	}
+
+	if key == "" {
+		key = NewIdempotencyKey()
+	}
+	r.Header.Set(HeaderIdempotencyKey, key)
+}
+
+// withDefaultIdempotencyKey makes key the idempotency key of the call if one
+// is required (see EDAPICaller.SetIdempotencyKeys), so that separate calls can
+// be made as the same logical call.
+func withDefaultIdempotencyKey(key string) CallOption {
+	return func(o *CallOptions) {
+		o.defaultIdempotencyKey = key
+	}
+}
+
+// needsIdempotencyKey returns true if requests with the given method may have
+// a different effect if made more than once, and so need an idempotency key.
+func needsIdempotencyKey(method string) bool {
+	return method == "POST" || method == "PATCH"
+}
+
+// requestSignedHeaders returns the headers to sign with SchemeV2 for request
+// r: signedHeaders, plus the Idempotency-Key header if r has one.
+func requestSignedHeaders(r *http.Request, signedHeaders []string) []string {
+	if r.Header.Get(HeaderIdempotencyKey) == "" {
+		return signedHeaders
+	}
+
+	i := sort.SearchStrings(signedHeaders, idempotencyKeyHeader)
+	if i < len(signedHeaders) && signedHeaders[i] == idempotencyKeyHeader {
+		return signedHeaders
+	}
+
+	hs := make([]string, 0, len(signedHeaders)+1)
+	hs = append(hs, signedHeaders[:i]...)
+	hs = append(hs, idempotencyKeyHeader)
+	return append(hs, signedHeaders[i:]...)
+}
@@ -0,0 +1,220 @@
+package els
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Idempotency Test Suite", func() {
+
+	var (
+		now, _  = time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+		server  *httptest.Server
+		sut     *EDAPICaller
+		mu      sync.Mutex
+		keys    []string
+		failing int
+	)
+
+	BeforeEach(func() {
+		keys, failing = nil, 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			keys = append(keys, r.Header.Get(HeaderIdempotencyKey))
+			fail := failing > 0
+			failing--
+			mu.Unlock()
+			if fail {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			} else if strings.HasSuffix(r.URL.Path, "/accessKeys") {
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"accessKeyId":"id","secretAccessKey":"secret"}`))
+			}
+		}))
+		u, err := url.Parse(server.URL)
+		Expect(err).To(BeNil())
+
+		tp := datetime.NewNowTimeProvider()
+		tp.SetNow(now)
+		sut = NewEDAPICaller(&http.Client{}, tp, time.Second, "")
+		sut.APIHandler.Scheme = u.Scheme
+		sut.APIHandler.Domain = u.Host
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	post := func() *http.Request {
+		r, err := http.NewRequest("POST", "/vendors/v1", strings.NewReader("{}"))
+		Expect(err).To(BeNil())
+		return r
+	}
+
+	It("generates random version 4 UUIDs", func() {
+		k := NewIdempotencyKey()
+		Expect(k).To(MatchRegexp(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`))
+		Expect(NewIdempotencyKey()).NotTo(Equal(k))
+	})
+
+	It("sends the same key with each retry of a POST", func() {
+		failing = 2
+		rep, err := sut.DoWith(nil, post(), WithRetry(2, 0))
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(keys).To(HaveLen(3))
+		Expect(keys[0]).NotTo(BeEmpty())
+		Expect(keys[1]).To(Equal(keys[0]))
+		Expect(keys[2]).To(Equal(keys[0]))
+
+		rep, err = sut.Do(nil, post(), nil, true)
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(keys[3]).NotTo(BeEmpty())
+		Expect(keys[3]).NotTo(Equal(keys[0]))
+	})
+
+	It("doesn't add a key to idempotent methods", func() {
+		rep, err := sut.Get(nil, "/vendors/v1", nil, true)
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(keys).To(Equal([]string{""}))
+	})
+
+	It("uses a key given in the options", func() {
+		rep, err := sut.DoWith(nil, post(), WithIdempotencyKey("given"))
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(keys).To(Equal([]string{"given"}))
+	})
+
+	It("doesn't use the key of the context for calls other than CreateAccessKey", func() {
+		ctx := IdempotencyKeyContext(context.Background(), "fromctx")
+		for i := 0; i < 2; i++ {
+			rep, err := sut.Do(ctx, post(), nil, true)
+			Expect(err).To(BeNil())
+			rep.Body.Close()
+		}
+		Expect(keys).To(HaveLen(2))
+		Expect(keys[0]).NotTo(Equal("fromctx"))
+		Expect(keys[1]).NotTo(Equal(keys[0]))
+	})
+
+	It("doesn't add a key to calls to third-party APIs", func() {
+		rep, err := sut.DoWith(nil, post(), ThirdParty(server.URL))
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(keys).To(Equal([]string{""}))
+	})
+
+	It("doesn't add keys if disabled", func() {
+		sut.SetIdempotencyKeys(false)
+		rep, err := sut.Do(nil, post(), nil, true)
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(keys).To(Equal([]string{""}))
+	})
+
+	It("adds a key to CreateAccessKey and reports it to observers", func() {
+		o := &recordingObserver{name: "o", events: &[]string{}}
+		sut.AddObserver(o)
+
+		ctx := IdempotencyKeyContext(context.Background(), "create-1")
+		_, _, err := sut.CreateAccessKey(ctx, "a@b.com", "pw", false, 0)
+		Expect(err).To(BeNil())
+		_, _, err = sut.CreateAccessKey(context.Background(), "a@b.com", "pw", false, 0)
+		Expect(err).To(BeNil())
+
+		Expect(keys[0]).To(Equal("create-1"))
+		Expect(keys[1]).NotTo(BeEmpty())
+		Expect(o.finished[0].IdempotencyKey).To(Equal("create-1"))
+	})
+
+	It("uses the same key for each retry of a batch item", func() {
+		failing = 1
+		r := post()
+		b := NewBatch(sut)
+		b.Retries = 1
+		results, _ := b.Run(context.Background(), []BatchItem{{Request: r, IsELSAPI: true}})
+		Expect(results[0].Err).To(BeNil())
+		Expect(keys).To(HaveLen(2))
+		Expect(keys[0]).NotTo(BeEmpty())
+		Expect(keys[1]).To(Equal(keys[0]))
+		Expect(r.Header.Get(HeaderIdempotencyKey)).To(BeEmpty())
+	})
+
+	It("uses a different key for each call made by a batch function", func() {
+		b := NewBatch(sut)
+		f := func(ctx context.Context, a APICaller) (interface{}, error) {
+			for i := 0; i < 2; i++ {
+				rep, err := a.Do(ctx, post(), nil, true)
+				if err != nil {
+					return nil, err
+				}
+				rep.Body.Close()
+			}
+			return nil, nil
+		}
+		results, _ := b.Run(context.Background(), []BatchItem{{Func: f}, CreateAccessKeyItem("a@b.com", "pw", false, 0)})
+		Expect(results[0].Err).To(BeNil())
+		Expect(results[1].Err).To(BeNil())
+		Expect(keys).To(HaveLen(3))
+		Expect(keys[0]).NotTo(BeEmpty())
+		Expect(keys[1]).NotTo(Equal(keys[0]))
+		Expect(keys[2]).NotTo(BeEmpty())
+		Expect(keys[2]).NotTo(Equal(keys[0]))
+		Expect(keys[2]).NotTo(Equal(keys[1]))
+	})
+
+	It("uses the same key for each retry of a CreateAccessKey batch item", func() {
+		failing = 1
+		b := NewBatch(sut)
+		b.Retries = 1
+		results, _ := b.Run(context.Background(), []BatchItem{CreateAccessKeyItem("a@b.com", "pw", false, 0)})
+		Expect(results[0].Err).To(BeNil())
+		Expect(keys).To(HaveLen(2))
+		Expect(keys[0]).NotTo(BeEmpty())
+		Expect(keys[1]).To(Equal(keys[0]))
+	})
+
+	Describe("Signing with SchemeV2", func() {
+		var (
+			k      = &AccessKey{ID: "id", SecretAccessKey: "secret"}
+			signer *APISigner
+			r      *http.Request
+		)
+
+		BeforeEach(func() {
+			var err error
+			signer, err = NewAPISignerV2(k)
+			Expect(err).To(BeNil())
+			r, err = http.NewRequest("POST", "https://api.elasticlicensing.com/1.0/vendors/v1", nil)
+			Expect(err).To(BeNil())
+			r.Header.Set(HeaderIdempotencyKey, "key-1")
+			Expect(signer.Sign(r, now)).To(BeNil())
+		})
+
+		It("signs the key", func() {
+			Expect(r.Header.Get(HeaderSignedHeaders)).To(Equal("content-type;host;idempotency-key;x-els-date"))
+			v := NewVerifier(KeyStoreFunc(func(id AccessKeyID) (*AccessKey, error) {
+				return k, nil
+			}))
+			_, err := v.Verify(r, now)
+			Expect(err).To(BeNil())
+
+			r.Header.Set(HeaderIdempotencyKey, "key-2")
+			_, err = v.Verify(r, now)
+			Expect(err).To(Equal(ErrSignatureMismatch))
+		})
+	})
+})
@@ -0,0 +1,154 @@
+package els
+
+import (
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// Stage identifies where in the processing of an API call an Interceptor is
+// run.
+type Stage int
+
+const (
+	// StageBeforeSign interceptors run once per call, before the request is
+	// signed. The built-in URL completion runs first, so they see the
+	// completed URL of ELS API requests. They may modify the request, e.g. to
+	// add headers which are to be signed or to transform the body.
+	StageBeforeSign Stage = iota
+
+	// StageAfterSign interceptors run each time the request is sent, after the
+	// built-in signing and immediately before the request is sent. Changes to
+	// the request made here are not signed.
+	StageAfterSign
+
+	// StageAfterResponse interceptors run each time a response is received,
+	// or served from the cache (see EDAPICaller.SetCache). They may inspect,
+	// validate or replace Exchange.Response. They are not run if no response
+	// was received.
+	StageAfterResponse
+)
+
+// Exchange holds the request and response of an API call as it passes through
+// the interceptors of an EDAPICaller.
+type Exchange struct {
+	// Call describes the call, as reported to observers.
+	Call *Call
+
+	// Request is the request being made. From StageAfterSign, it is the copy
+	// of the request signed and sent in the current attempt.
+	Request *http.Request
+
+	// Signer signs the request, or is nil if the request is not signed.
+	Signer Signer
+
+	// IsELSAPI is true if the call is to the ELS API.
+	IsELSAPI bool
+
+	// SignTime is the time with which the request is signed. It is set before
+	// each attempt to send the request.
+	SignTime time.Time
+
+	// Response is the response received. It is nil before StageAfterResponse.
+	Response *http.Response
+
+	// sendFailed is true if the last attempt failed to get a response, rather
+	// than being stopped by an interceptor.
+	sendFailed bool
+}
+
+// Interceptor is run by an EDAPICaller at a given Stage of each API call,
+// allowing requests and responses to be modified or checked without changing
+// the code which makes the calls. If it returns an error, the call stops and
+// returns the error (closing the body of any response). Interceptors are
+// added with EDAPICaller.Use and must be safe for concurrent use.
+type Interceptor interface {
+	Intercept(ctx context.Context, e *Exchange) error
+}
+
+// InterceptorFunc allows an ordinary function to be used as an Interceptor.
+type InterceptorFunc func(ctx context.Context, e *Exchange) error
+
+// Intercept implements interface Interceptor by calling f.
+func (f InterceptorFunc) Intercept(ctx context.Context, e *Exchange) error {
+	return f(ctx, e)
+}
+
+// stagedInterceptor is an interceptor added with Use, and its stage.
+type stagedInterceptor struct {
+	stage Stage
+	i     Interceptor
+}
+
+// Use adds an interceptor to run at the given stage of all subsequent API
+// calls, after the interceptors already added to that stage.
+func (a *EDAPICaller) Use(stage Stage, i Interceptor) {
+	a.Lock()
+	defer a.Unlock()
+	a.interceptors = append(a.interceptors, stagedInterceptor{stage: stage, i: i})
+}
+
+// getInterceptors returns the interceptors to run at the given stage: the
+// built-in interceptors followed by those added with Use.
+func (a *EDAPICaller) getInterceptors(stage Stage) []Interceptor {
+	var is []Interceptor
+	switch stage {
+	case StageBeforeSign:
+		is = append(is, InterceptorFunc(a.completeURL))
+	case StageAfterSign:
+		is = append(is, InterceptorFunc(a.signRequest))
+	}
+
+	a.RLock()
+	defer a.RUnlock()
+	for _, si := range a.interceptors {
+		if si.stage == stage {
+			is = append(is, si.i)
+		}
+	}
+	return is
+}
+
+// intercept runs the interceptors of the given stage in order, stopping at
+// the first error.
+func (a *EDAPICaller) intercept(ctx context.Context, stage Stage, e *Exchange) error {
+	for _, i := range a.getInterceptors(stage) {
+		if err := i.Intercept(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completeURL is the built-in interceptor which completes the URL of ELS API
+// requests.
+func (a *EDAPICaller) completeURL(ctx context.Context, e *Exchange) error {
+	if e.IsELSAPI {
+		a.APIHandler.CompleteURL(e.Request.URL)
+	}
+	return nil
+}
+
+// signRequest is the built-in interceptor which ELS-signs the request, if it
+// has a signer. It runs before the interceptors added to StageAfterSign. ELS
+// API requests are signed for the API version of the caller if the signer is
+// a VersionSigner.
+func (a *EDAPICaller) signRequest(ctx context.Context, e *Exchange) error {
+	if e.Signer == nil {
+		return nil
+	}
+	var err error
+	if vs, ok := e.Signer.(VersionSigner); ok && e.IsELSAPI {
+		err = vs.SignVersion(e.Request, e.SignTime, a.APIHandler.Version)
+	} else {
+		err = e.Signer.Sign(e.Request, e.SignTime)
+	}
+	if err != nil {
+		e.Call.SignErr = err
+		log.WithFields(log.Fields{"Time": time.Now(), "err": err}).Debug("ApiCaller: Failed to sign")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package els
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Interceptor Test Suite", func() {
+
+	var (
+		sut        *EDAPICaller
+		server     *httptest.Server
+		signer     *DummySigner
+		statusCode int
+		reqRec     *http.Request
+		rep        *http.Response
+		err        error
+
+		mu     sync.Mutex
+		events []string
+
+		// recorder returns an interceptor which records its name and the
+		// request's url and Authorization header.
+		recorder = func(name string) Interceptor {
+			return InterceptorFunc(func(ctx context.Context, e *Exchange) error {
+				mu.Lock()
+				defer mu.Unlock()
+				events = append(events, name+" "+e.Request.URL.Path+" "+e.Request.Header.Get("Authorization"))
+				return nil
+			})
+		}
+	)
+
+	BeforeEach(func() {
+		events = nil
+		reqRec = nil
+		statusCode = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqRec = r
+			w.WriteHeader(statusCode)
+		}))
+		u, perr := url.Parse(server.URL)
+		Expect(perr).To(BeNil())
+
+		sut = NewEDAPICaller(&http.Client{}, datetime.NewNowTimeProvider(), time.Second, "")
+		sut.APIHandler.Scheme = u.Scheme
+		sut.APIHandler.Domain = u.Host
+		signer = &DummySigner{}
+	})
+
+	AfterEach(func() {
+		if rep != nil {
+			rep.Body.Close()
+		}
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		rep, err = sut.Get(nil, "/vendors/v1", signer, true)
+	})
+
+	Context("Interceptors are added to each stage", func() {
+		BeforeEach(func() {
+			sut.Use(StageAfterResponse, recorder("response"))
+			sut.Use(StageAfterSign, recorder("after"))
+			sut.Use(StageBeforeSign, recorder("before1"))
+			sut.Use(StageBeforeSign, recorder("before2"))
+		})
+		It("runs them in stage order, around the built-in URL completion and signing", func() {
+			Expect(err).To(BeNil())
+			Expect(events).To(Equal([]string{
+				"before1 /1.0/vendors/v1 ",
+				"before2 /1.0/vendors/v1 ",
+				"after /1.0/vendors/v1 some auth",
+				"response /1.0/vendors/v1 some auth",
+			}))
+		})
+	})
+
+	Context("A before-sign interceptor adds a header", func() {
+		BeforeEach(func() {
+			sut.Use(StageBeforeSign, InterceptorFunc(func(ctx context.Context, e *Exchange) error {
+				e.Request.Header.Set("X-Correlation-Id", "abc")
+				return nil
+			}))
+		})
+		It("is sent with the request", func() {
+			Expect(reqRec.Header.Get("X-Correlation-Id")).To(Equal("abc"))
+			Expect(signer.LastRequest.Header.Get("X-Correlation-Id")).To(Equal("abc"))
+		})
+	})
+
+	Context("A before-sign interceptor fails", func() {
+		BeforeEach(func() {
+			sut.Use(StageBeforeSign, InterceptorFunc(func(ctx context.Context, e *Exchange) error {
+				return errors.New("rejected")
+			}))
+		})
+		It("returns the error without signing or sending the request", func() {
+			Expect(err).To(MatchError("rejected"))
+			Expect(rep).To(BeNil())
+			Expect(signer.LastRequest).To(BeNil())
+			Expect(reqRec).To(BeNil())
+		})
+	})
+
+	Context("An after-response interceptor validates the response", func() {
+		BeforeEach(func() {
+			statusCode = http.StatusTeapot
+			sut.Use(StageAfterResponse, InterceptorFunc(func(ctx context.Context, e *Exchange) error {
+				if e.Response.StatusCode != http.StatusOK {
+					return ErrUnexpectedStatusCode
+				}
+				return nil
+			}))
+		})
+		It("returns its error", func() {
+			Expect(err).To(Equal(ErrUnexpectedStatusCode))
+			Expect(rep).To(BeNil())
+		})
+	})
+})
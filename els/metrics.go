@@ -0,0 +1,97 @@
+package els
+
+import (
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// The error types reported to MetricsCollector.IncSigningFailure.
+const (
+	SignErrNoRequest        = "no_request"
+	SignErrInvalidURL       = "invalid_url"
+	SignErrExpiredAccessKey = "expired_access_key"
+	SignErrNoBodyDigest     = "no_body_digest"
+	SignErrOther            = "other"
+)
+
+// MetricsCollector receives metrics describing the API calls made by an
+// EDAPICaller; see package elsprom for a Prometheus implementation. Collectors
+// are added with EDAPICaller.AddMetricsCollector and must be safe for
+// concurrent use.
+type MetricsCollector interface {
+	// ObserveRequest records a call which sent its request to the given route,
+	// its final status code (0 if no response was received) and its duration.
+	ObserveRequest(route, method string, statusCode int, d time.Duration)
+
+	// IncTimeout records a call to the given route which failed to get a
+	// response. These are the events which update LastTimeout.
+	IncTimeout(route string)
+
+	// IncSigningFailure records a call to the given route whose request could
+	// not be signed. errType is one of the SignErr constants.
+	IncSigningFailure(route, errType string)
+
+	// ObserveKeyExpiry records the expiry date of the access key with the
+	// given ID, each time it is used to sign a call. The zero time means the
+	// key never expires.
+	ObserveKeyExpiry(id AccessKeyID, expiry time.Time)
+}
+
+// KeyInfo is implemented by signers which can describe the access key they
+// sign with, such as APISigner.
+type KeyInfo interface {
+	AccessKeyID() AccessKeyID
+	ExpiryDate() time.Time
+}
+
+// SigningErrorType returns the SignErr constant which classifies err, an error
+// returned by Signer.Sign.
+func SigningErrorType(err error) string {
+	switch err {
+	case ErrNoRequest:
+		return SignErrNoRequest
+	case ErrRequestInvalidURL:
+		return SignErrInvalidURL
+	case ErrExpiredAccessKey:
+		return SignErrExpiredAccessKey
+	case ErrNoBodyDigest:
+		return SignErrNoBodyDigest
+	}
+	return SignErrOther
+}
+
+// AddMetricsCollector adds a collector which will receive the metrics of all
+// subsequent API calls.
+func (a *EDAPICaller) AddMetricsCollector(m MetricsCollector) {
+	a.AddObserver(&metricsObserver{m: m})
+}
+
+// metricsObserver implements interface CallObserver by reporting the outcome
+// of each call to a MetricsCollector.
+type metricsObserver struct {
+	m MetricsCollector
+}
+
+func (o *metricsObserver) CallStarted(ctx context.Context, c *Call) context.Context {
+	if k, ok := c.Signer.(KeyInfo); ok {
+		o.m.ObserveKeyExpiry(k.AccessKeyID(), k.ExpiryDate())
+	}
+	return ctx
+}
+
+func (o *metricsObserver) BeforeSend(ctx context.Context, c *Call, r *http.Request) {}
+
+func (o *metricsObserver) CallFinished(ctx context.Context, c *Call) {
+	if c.SignErr != nil {
+		o.m.IncSigningFailure(c.Route, SigningErrorType(c.SignErr))
+		return
+	}
+
+	if c.TimedOut {
+		o.m.IncTimeout(c.Route)
+	}
+
+	o.m.ObserveRequest(c.Route, c.Method, c.StatusCode, c.Duration)
+}
@@ -0,0 +1,125 @@
+package els
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recordingCollector implements interface MetricsCollector, recording the
+// metrics it receives as strings.
+type recordingCollector struct {
+	sync.Mutex
+	metrics []string
+}
+
+func (m *recordingCollector) ObserveRequest(route, method string, statusCode int, d time.Duration) {
+	m.record(fmt.Sprintf("request %s %s %d", route, method, statusCode))
+}
+
+func (m *recordingCollector) IncTimeout(route string) {
+	m.record("timeout " + route)
+}
+
+func (m *recordingCollector) IncSigningFailure(route, errType string) {
+	m.record("signing failure " + route + " " + errType)
+}
+
+func (m *recordingCollector) ObserveKeyExpiry(id AccessKeyID, expiry time.Time) {
+	m.record("expiry " + string(id) + " " + expiry.UTC().Format(time.RFC3339))
+}
+
+func (m *recordingCollector) record(s string) {
+	m.Lock()
+	defer m.Unlock()
+	m.metrics = append(m.metrics, s)
+}
+
+var _ = Describe("Metrics Test Suite", func() {
+
+	var (
+		now, _ = time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+		sut    *EDAPICaller
+		server *httptest.Server
+		m      *recordingCollector
+		s      Signer
+		err    error
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		u, perr := url.Parse(server.URL)
+		Expect(perr).To(BeNil())
+
+		tp := datetime.NewNowTimeProvider()
+		tp.SetNow(now)
+		sut = NewEDAPICaller(&http.Client{}, tp, time.Second, "")
+		sut.APIHandler.Scheme = u.Scheme
+		sut.APIHandler.Domain = u.Host
+
+		m = &recordingCollector{}
+		sut.AddMetricsCollector(m)
+
+		s, err = NewAPISigner(&AccessKey{ID: "id", SecretAccessKey: "secret", ExpiryDate: now.Add(time.Hour)})
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		var rep *http.Response
+		rep, err = sut.Get(nil, "/vendors/v1", s, true)
+		if rep != nil {
+			rep.Body.Close()
+		}
+	})
+
+	It("reports the key's expiry and the request's status", func() {
+		Expect(err).To(BeNil())
+		Expect(m.metrics).To(Equal([]string{
+			"expiry id 2015-01-01T01:00:00Z",
+			"request unknown GET 404",
+		}))
+	})
+
+	Context("The request can't be sent", func() {
+		BeforeEach(func() {
+			server.Close()
+			s = nil
+		})
+		It("reports a timeout", func() {
+			Expect(err).NotTo(BeNil())
+			Expect(m.metrics).To(Equal([]string{"timeout unknown", "request unknown GET 0"}))
+		})
+	})
+
+	Context("The request can't be signed", func() {
+		BeforeEach(func() {
+			s = &DummySigner{ErrToReturn: ErrNoBodyDigest}
+		})
+		It("reports a signing failure instead of a request", func() {
+			Expect(err).To(Equal(ErrNoBodyDigest))
+			Expect(m.metrics).To(Equal([]string{"signing failure unknown no_body_digest"}))
+		})
+	})
+
+	Describe("SigningErrorType", func() {
+		It("classifies signing errors", func() {
+			Expect(SigningErrorType(ErrExpiredAccessKey)).To(Equal(SignErrExpiredAccessKey))
+			Expect(SigningErrorType(errors.New("boom"))).To(Equal(SignErrOther))
+		})
+	})
+})
@@ -138,6 +138,30 @@ func (m *APICaller) Get(ctx context.Context, URL string, s els.Signer, isELSAPI
 	return r.Rep, r.Err
 }
 
+// DoWith implements interface core.APICaller
+func (m *APICaller) DoWith(ctx context.Context, req *http.Request, opts ...els.CallOption) (*http.Response, error) {
+	a, r := m.initNextCall("DoWith")
+	a.Context = ctx
+	a.Req = req
+	a.setOptions(opts)
+
+	defer m.endCall()
+
+	return r.Rep, r.Err
+}
+
+// GetWith implements interface core.APICaller
+func (m *APICaller) GetWith(ctx context.Context, URL string, opts ...els.CallOption) (*http.Response, error) {
+	a, r := m.initNextCall("GetWith")
+	a.Context = ctx
+	a.URL = URL
+	a.setOptions(opts)
+
+	defer m.endCall()
+
+	return r.Rep, r.Err
+}
+
 // LastTimeout implements interface core.APICaller
 func (m *APICaller) LastTimeout() time.Time {
 	return m.LastTo
@@ -215,6 +239,17 @@ type ACArgs struct {
 	// IsELSAPI stores the flag used to determine if calling the ELS API or a
 	// third party API.
 	IsELSAPI bool
+
+	// Options are the settings resulting from the options passed to DoWith or
+	// GetWith. Signer and IsELSAPI are also set from them.
+	Options els.CallOptions
+}
+
+// setOptions records the settings resulting from opts.
+func (a *ACArgs) setOptions(opts []els.CallOption) {
+	a.Options = els.NewCallOptions(opts...)
+	a.Signer = a.Options.Signer
+	a.IsELSAPI = a.Options.IsELSAPI
 }
 
 // ACRep represents the simulated response to be returned when an API call
@@ -0,0 +1,161 @@
+package els
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MultipartFile is a file to upload in a multipart/form-data request built by
+// NewMultipartRequest.
+type MultipartFile struct {
+	// Field is the name of the form field holding the file.
+	Field string
+
+	// Name is the file name sent with the file.
+	Name string
+
+	// ContentType is the content type of the file. If empty,
+	// "application/octet-stream" is used.
+	ContentType string
+
+	// Open returns a reader for the content of the file. It is called each
+	// time the body of the request is read, so that the request can be
+	// digested for signing and resent without holding the file in memory.
+	Open func() (io.ReadCloser, error)
+}
+
+// FileFromPath returns a MultipartFile which uploads the file at path in the
+// given field.
+func FileFromPath(field string, path string) MultipartFile {
+	return MultipartFile{
+		Field: field,
+		Name:  filepath.Base(path),
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+	}
+}
+
+// FileFromBytes returns a MultipartFile which uploads b as the file with the
+// given name in the given field.
+func FileFromBytes(field string, name string, b []byte) MultipartFile {
+	return MultipartFile{
+		Field: field,
+		Name:  name,
+		Open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		},
+	}
+}
+
+// NewMultipartRequest returns a request with a multipart/form-data body
+// holding the given form fields and files, which can be signed and sent like
+// any other (the multipart content type is signed). The body is generated as
+// it is read, and can be read again (see http.Request.GetBody), so large files
+// are not held in memory.
+func NewMultipartRequest(method string, url string, fields url.Values, files ...MultipartFile) (*http.Request, error) {
+	boundary := multipart.NewWriter(nil).Boundary()
+
+	body := func() (io.ReadCloser, error) {
+		return &lazyBody{open: func() io.ReadCloser {
+			pr, pw := io.Pipe()
+			go func() {
+				pw.CloseWithError(writeMultipart(pw, boundary, fields, files))
+			}()
+			return pr
+		}}, nil
+	}
+
+	rc, _ := body()
+	r, err := http.NewRequest(method, url, rc)
+	if err != nil {
+		return nil, err
+	}
+	r.GetBody = body
+	r.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+
+	return r, nil
+}
+
+// quoteEscaper escapes the quoted values in the headers of a multipart part.
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// writeMultipart writes the multipart body holding fields and files to w,
+// using the given boundary. Fields are written in order of name.
+func writeMultipart(w io.Writer, boundary string, fields url.Values, files []MultipartFile) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range fields[name] {
+			if err := mw.WriteField(name, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range files {
+		ct := f.ContentType
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", `form-data; name="`+quoteEscaper.Replace(f.Field)+`"; filename="`+quoteEscaper.Replace(f.Name)+`"`)
+		h.Set("Content-Type", ct)
+
+		pw, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(pw, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return mw.Close()
+}
+
+// lazyBody is a request body which is only opened when first read, so that
+// nothing is left running if it is never read.
+type lazyBody struct {
+	open func() io.ReadCloser
+	rc   io.ReadCloser
+}
+
+// Read implements interface io.Reader, opening the body if necessary.
+func (b *lazyBody) Read(p []byte) (int, error) {
+	if b.rc == nil {
+		b.rc = b.open()
+	}
+	return b.rc.Read(p)
+}
+
+// Close implements interface io.Closer, closing the body if it was opened.
+func (b *lazyBody) Close() error {
+	if b.rc == nil {
+		return nil
+	}
+	return b.rc.Close()
+}
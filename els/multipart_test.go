@@ -0,0 +1,51 @@
+package els
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Multipart Test Suite", func() {
+
+	Describe("NewMultipartRequest", func() {
+
+		It("builds a multipart body which can be read again", func() {
+			r, err := NewMultipartRequest("POST", "/1.0/files",
+				url.Values{"b": {"2"}, "a": {"1"}},
+				FileFromBytes("file", `a "quoted".txt`, []byte("content")))
+			Expect(err).To(BeNil())
+
+			mt, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			Expect(err).To(BeNil())
+			Expect(mt).To(Equal("multipart/form-data"))
+
+			first, err := ioutil.ReadAll(r.Body)
+			Expect(err).To(BeNil())
+			rc, err := r.GetBody()
+			Expect(err).To(BeNil())
+			second, err := ioutil.ReadAll(rc)
+			Expect(err).To(BeNil())
+			Expect(second).To(Equal(first))
+			Expect(strings.Index(string(first), `name="a"`)).To(BeNumerically("<", strings.Index(string(first), `name="b"`)))
+
+			f, err := multipart.NewReader(strings.NewReader(string(first)), params["boundary"]).ReadForm(1 << 20)
+			Expect(err).To(BeNil())
+			Expect(f.Value["a"]).To(Equal([]string{"1"}))
+			Expect(f.File["file"][0].Filename).To(Equal(`a "quoted".txt`))
+			Expect(f.File["file"][0].Header.Get("Content-Type")).To(Equal("application/octet-stream"))
+		})
+
+		It("fails to read the body if a file can't be opened", func() {
+			r, err := NewMultipartRequest("POST", "/1.0/files", nil, FileFromPath("file", "/no/such/file"))
+			Expect(err).To(BeNil())
+			_, err = ioutil.ReadAll(r.Body)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
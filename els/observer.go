@@ -0,0 +1,197 @@
+package els
+
+import (
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// The operations reported in Call.Operation.
+const (
+	OpDo              = "Do"
+	OpGet             = "Get"
+	OpCreateAccessKey = "CreateAccessKey"
+)
+
+// RouteUnknown is the Call.Route of ELS API calls whose route template is not
+// known.
+const RouteUnknown = "unknown"
+
+// CallObserver is notified of the progress of each API call made by an
+// EDAPICaller, allowing calls to be traced and measured without changing the
+// code which makes them. See package elsotel for an OpenTelemetry
+// implementation. Observers are added with EDAPICaller.AddObserver and must
+// be safe for concurrent use.
+type CallObserver interface {
+	// CallStarted is called when a call begins. The returned context (which
+	// may simply be ctx) is used for the remainder of the call, and is passed
+	// to the observer's other methods.
+	CallStarted(ctx context.Context, c *Call) context.Context
+
+	// BeforeSend is called before each attempt to send the request of a call,
+	// before it is signed. The observer may add headers to r, e.g. to
+	// propagate trace context.
+	BeforeSend(ctx context.Context, c *Call, r *http.Request)
+
+	// CallFinished is called when a call completes, successfully or not.
+	CallFinished(ctx context.Context, c *Call)
+}
+
+// Call describes an API call made by an EDAPICaller, for the benefit of a
+// CallObserver. Fields describing the outcome are set before CallFinished is
+// called.
+type Call struct {
+	// Operation is the EDAPICaller method called: OpDo, OpGet or
+	// OpCreateAccessKey.
+	Operation string
+
+	// Method is the http method of the request.
+	Method string
+
+	// Route identifies the API route called. It is the template given with
+	// WithRoute, or by RouteBuilder.NewRequest, if any. Otherwise it is
+	// RouteUnknown for ELS API calls, as paths may hold any number of
+	// distinct values (some personal, such as email addresses), and the host
+	// for third-party calls.
+	Route string
+
+	// IsELSAPI is true if the call is to the ELS API.
+	IsELSAPI bool
+
+	// Signer is the signer used to sign the request, if any.
+	Signer Signer
+
+	// IdempotencyKey is the Idempotency-Key header of the request, if any.
+	// Retries of the same call have the same key.
+	IdempotencyKey string
+
+	// Start is when the call began.
+	Start time.Time
+
+	// Attempts is the number of times the request was sent, including
+	// retries (see WithRetry).
+	Attempts int
+
+	// AttemptDurations is how long each attempt took to get a response or
+	// fail.
+	AttemptDurations []time.Duration
+
+	// StatusCode is the status code of the final response, or 0 if none was
+	// received.
+	StatusCode int
+
+	// Err is the error returned by the call, if any.
+	Err error
+
+	// SignErr is the error returned by the signer, if signing failed.
+	SignErr error
+
+	// FromCache is true if the response was taken from the cache (see
+	// EDAPICaller.SetCache) without sending the request.
+	FromCache bool
+
+	// TimedOut is true if the call failed to get a response, in which case
+	// it updated LastTimeout.
+	TimedOut bool
+
+	// Duration is how long the call took.
+	Duration time.Duration
+}
+
+// routeKey is the context key under which WithRoute stores a route.
+type routeKey struct{}
+
+// WithRoute returns a copy of ctx which tells observers of an API call made
+// with it that the call is to the given route. Use a template (e.g.
+// "/users/{email}") rather than the actual path so that calls to the same
+// route can be aggregated. A request made with RouteBuilder.NewRequest
+// carries the template of its route in its own context.
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+// RouteFromContext returns the route set with WithRoute, or "" if none was
+// set.
+func RouteFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	r, _ := ctx.Value(routeKey{}).(string)
+	return r
+}
+
+// AddObserver adds an observer which will be notified of all subsequent API
+// calls.
+func (a *EDAPICaller) AddObserver(o CallObserver) {
+	a.Lock()
+	defer a.Unlock()
+	a.observers = append(a.observers, o)
+}
+
+// newCall returns a Call describing an API call about to be made with the
+// request r and signer s.
+func newCall(ctx context.Context, op string, r *http.Request, s Signer, isELSAPI bool) *Call {
+	c := &Call{
+		Operation: op,
+		Method:    r.Method,
+		Route:     RouteFromContext(ctx),
+		IsELSAPI:  isELSAPI,
+		Signer:    s,
+		Start:     time.Now(),
+	}
+
+	if c.Route == "" {
+		c.Route = RouteFromContext(r.Context())
+	}
+	if c.Route == "" {
+		if isELSAPI {
+			c.Route = RouteUnknown
+		} else {
+			c.Route = r.URL.Host
+		}
+	}
+
+	return c
+}
+
+// getObservers returns the observers registered with the caller.
+func (a *EDAPICaller) getObservers() []CallObserver {
+	a.RLock()
+	defer a.RUnlock()
+	return a.observers
+}
+
+// callStarted notifies the observers that call c has started, returning the
+// context to use for the call.
+func (a *EDAPICaller) callStarted(ctx context.Context, c *Call) context.Context {
+	for _, o := range a.getCallObservers(ctx) {
+		ctx = o.CallStarted(ctx, c)
+	}
+	return ctx
+}
+
+// beforeSend notifies the observers that the request r of call c is about to
+// be signed and sent, and records the attempt.
+func (a *EDAPICaller) beforeSend(ctx context.Context, c *Call, r *http.Request) {
+	c.Attempts++
+	c.IdempotencyKey = r.Header.Get(HeaderIdempotencyKey)
+	for _, o := range a.getCallObservers(ctx) {
+		o.BeforeSend(ctx, c, r)
+	}
+}
+
+// callFinished records the outcome of call c and notifies the observers, in
+// the reverse order to which they were notified of its start.
+func (a *EDAPICaller) callFinished(ctx context.Context, c *Call, resp *http.Response, err error) {
+	c.Duration = time.Since(c.Start)
+	c.Err = err
+	if resp != nil {
+		c.StatusCode = resp.StatusCode
+	}
+
+	os := a.getCallObservers(ctx)
+	for i := len(os) - 1; i >= 0; i-- {
+		os[i].CallFinished(ctx, c)
+	}
+}
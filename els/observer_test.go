@@ -0,0 +1,179 @@
+package els
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recordingObserver implements interface CallObserver, recording the calls
+// and events it is notified of.
+type recordingObserver struct {
+	sync.Mutex
+	name     string
+	events   *[]string
+	finished []Call
+}
+
+type observerKey struct{}
+
+func (o *recordingObserver) CallStarted(ctx context.Context, c *Call) context.Context {
+	o.record("started " + c.Operation)
+	return context.WithValue(ctx, observerKey{}, o.name)
+}
+
+func (o *recordingObserver) BeforeSend(ctx context.Context, c *Call, r *http.Request) {
+	o.record("send")
+	r.Header.Set("X-Observed-By", ctx.Value(observerKey{}).(string))
+}
+
+func (o *recordingObserver) CallFinished(ctx context.Context, c *Call) {
+	o.record("finished " + c.Operation)
+	o.Lock()
+	defer o.Unlock()
+	o.finished = append(o.finished, *c)
+}
+
+func (o *recordingObserver) record(e string) {
+	o.Lock()
+	defer o.Unlock()
+	*o.events = append(*o.events, o.name+": "+e)
+}
+
+var _ = Describe("Observer Test Suite", func() {
+
+	var (
+		sut        *EDAPICaller
+		server     *httptest.Server
+		observer   *recordingObserver
+		events     []string
+		statusCode int
+		reqRec     *http.Request
+		rep        *http.Response
+		err        error
+	)
+
+	BeforeEach(func() {
+		events = nil
+		statusCode = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqRec = r
+			w.WriteHeader(statusCode)
+		}))
+		u, perr := url.Parse(server.URL)
+		Expect(perr).To(BeNil())
+
+		sut = NewEDAPICaller(&http.Client{}, datetime.NewNowTimeProvider(), time.Second, "")
+		sut.APIHandler.Scheme = u.Scheme
+		sut.APIHandler.Domain = u.Host
+
+		observer = &recordingObserver{name: "o1", events: &events}
+		sut.AddObserver(observer)
+	})
+
+	AfterEach(func() {
+		if rep != nil {
+			rep.Body.Close()
+		}
+		server.Close()
+	})
+
+	Describe("Do", func() {
+		var ctx context.Context
+
+		BeforeEach(func() {
+			ctx = nil
+		})
+
+		JustBeforeEach(func() {
+			req, rerr := http.NewRequest("GET", "/vendors/v1", nil)
+			Expect(rerr).To(BeNil())
+			rep, err = sut.Do(ctx, req, &DummySigner{}, true)
+		})
+
+		It("reports the call", func() {
+			Expect(err).To(BeNil())
+			Expect(events).To(Equal([]string{"o1: started Do", "o1: send", "o1: finished Do"}))
+			c := observer.finished[0]
+			Expect(c.Method).To(Equal("GET"))
+			Expect(c.Route).To(Equal(RouteUnknown))
+			Expect(c.IsELSAPI).To(BeTrue())
+			Expect(c.Attempts).To(Equal(1))
+			Expect(c.StatusCode).To(Equal(http.StatusOK))
+			Expect(c.Err).To(BeNil())
+			Expect(c.Duration).To(BeNumerically(">", 0))
+		})
+
+		It("lets the observer add headers using the context it returned", func() {
+			Expect(reqRec.Header.Get("X-Observed-By")).To(Equal("o1"))
+		})
+
+		Context("A route is set in the context", func() {
+			BeforeEach(func() {
+				ctx = WithRoute(context.Background(), "/vendors/{id}")
+			})
+			It("reports the route", func() {
+				Expect(observer.finished[0].Route).To(Equal("/vendors/{id}"))
+			})
+		})
+
+		It("reports the template of a route built with NewRoute", func() {
+			req, rerr := NewRoute("/vendors/{id}").Set("id", "v2").NewRequest("GET", nil)
+			Expect(rerr).To(BeNil())
+			rep, err = sut.Do(nil, req, &DummySigner{}, true)
+			Expect(err).To(BeNil())
+			rep.Body.Close()
+			Expect(observer.finished[1].Route).To(Equal("/vendors/{id}"))
+		})
+
+		Context("There are several observers", func() {
+			BeforeEach(func() {
+				sut.AddObserver(&recordingObserver{name: "o2", events: &events})
+			})
+			It("notifies them in order when starting and in reverse order when finishing", func() {
+				Expect(events).To(Equal([]string{
+					"o1: started Do", "o2: started Do",
+					"o1: send", "o2: send",
+					"o2: finished Do", "o1: finished Do",
+				}))
+				Expect(reqRec.Header.Get("X-Observed-By")).To(Equal("o2"))
+			})
+		})
+	})
+
+	Describe("Get", func() {
+		JustBeforeEach(func() {
+			rep, err = sut.Get(nil, "/vendors/v1", nil, true)
+		})
+		It("reports the call as a Get", func() {
+			Expect(err).To(BeNil())
+			Expect(observer.finished[0].Operation).To(Equal(OpGet))
+		})
+	})
+
+	Describe("CreateAccessKey", func() {
+		BeforeEach(func() {
+			statusCode = http.StatusUnauthorized
+		})
+		JustBeforeEach(func() {
+			_, _, err = sut.CreateAccessKey(context.Background(), "a@b.com", "pw", false, 0)
+		})
+		It("reports the call", func() {
+			Expect(err).To(Equal(ErrUnexpectedStatusCode))
+			Expect(events).To(Equal([]string{"o1: started CreateAccessKey", "o1: send", "o1: finished CreateAccessKey"}))
+			c := observer.finished[0]
+			Expect(c.Route).To(Equal("/users/{email}/accessKeys"))
+			Expect(c.StatusCode).To(Equal(http.StatusUnauthorized))
+			Expect(c.Err).To(Equal(ErrUnexpectedStatusCode))
+			Expect(reqRec.Header.Get("X-Observed-By")).To(Equal("o1"))
+		})
+	})
+})
@@ -0,0 +1,297 @@
+package els
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// HeaderIdempotencyKey is the header set by WithIdempotencyKey.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// CallOptions holds the settings of a call made with DoWith or GetWith, as
+// set by the CallOption values passed.
+type CallOptions struct {
+	// Signer signs the request, or is nil if it is not to be signed.
+	Signer Signer
+
+	// IsELSAPI is true (the default) if the call is to the ELS API, so that
+	// its URL is completed.
+	IsELSAPI bool
+
+	// BaseURL, if set, is prefixed to the relative URLs of calls to a
+	// third-party API.
+	BaseURL string
+
+	// Timeout limits the call. If 0, the caller's default timeout applies
+	// unless the context has a deadline.
+	Timeout time.Duration
+
+	// Retries is the number of times the call is retried if it fails to get
+	// a response or gets a 429 or 5xx response, waiting RetryDelay between
+	// attempts.
+	Retries    int
+	RetryDelay time.Duration
+
+	// Header holds headers to set on the request.
+	Header http.Header
+
+	// IdempotencyKey, if set, is sent in the Idempotency-Key header instead
+	// of a generated key (see EDAPICaller.SetIdempotencyKeys).
+	IdempotencyKey string
+
+	// Service, if set, is the name of the third-party service called (see
+	// WithService).
+	Service string
+
+	// Response, if set, is filled with the response and metadata of the call
+	// (see WithResponse).
+	Response *Response
+
+	// NoCache is true if the call neither uses the cache nor is coalesced
+	// with other calls (see WithoutCache).
+	NoCache bool
+
+	// ctxTimeout is true if only the context limits the call, as with Do.
+	ctxTimeout bool
+
+	// defaultIdempotencyKey, if set, is used instead of a generated key.
+	defaultIdempotencyKey string
+}
+
+// CallOption sets an option of a call made with DoWith or GetWith.
+type CallOption func(o *CallOptions)
+
+// NewCallOptions returns the settings resulting from applying opts to the
+// defaults: an unsigned call to the ELS API.
+func NewCallOptions(opts ...CallOption) CallOptions {
+	o := CallOptions{IsELSAPI: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithSigner signs the request with s.
+func WithSigner(s Signer) CallOption {
+	return func(o *CallOptions) {
+		o.Signer = s
+	}
+}
+
+// WithTimeout limits the call to d, overriding the caller's default timeout.
+func WithTimeout(d time.Duration) CallOption {
+	return func(o *CallOptions) {
+		o.Timeout = d
+	}
+}
+
+// WithRetry retries the call up to n times if it fails to get a response or
+// gets a 429 or 5xx response, waiting delay between attempts. Calls are only
+// retried if their body can be resent (see Do). Observers are notified of the
+// call once, with each retry as an attempt (see Call.Attempts).
+func WithRetry(n int, delay time.Duration) CallOption {
+	return func(o *CallOptions) {
+		o.Retries = n
+		o.RetryDelay = delay
+	}
+}
+
+// ThirdParty makes a call to a third-party API rather than the ELS API. If
+// baseURL is not empty, it is prefixed to the URL of the request if that is
+// relative.
+func ThirdParty(baseURL string) CallOption {
+	return func(o *CallOptions) {
+		o.IsELSAPI = false
+		o.BaseURL = baseURL
+	}
+}
+
+// WithHeaders sets the given headers on the request, replacing any values it
+// already has for them.
+func WithHeaders(h http.Header) CallOption {
+	return func(o *CallOptions) {
+		if o.Header == nil {
+			o.Header = http.Header{}
+		}
+		for k, vs := range h {
+			o.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), vs...)
+		}
+	}
+}
+
+// WithIdempotencyKey sends key in the Idempotency-Key header, so that the
+// server can recognise retries of the call.
+func WithIdempotencyKey(key string) CallOption {
+	return func(o *CallOptions) {
+		o.IdempotencyKey = key
+	}
+}
+
+// WithoutCache makes the call reach the server: its response is neither taken
+// from nor stored in the cache (see EDAPICaller.SetCache), and it is not
+// coalesced with identical calls (see EDAPICaller.SetCoalescing).
+func WithoutCache() CallOption {
+	return func(o *CallOptions) {
+		o.NoCache = true
+	}
+}
+
+// withContextTimeout limits the call only by its context, or by the caller's
+// default timeout if the context is nil, as Do and Get always have. The
+// context may then be nil, so the call must not be retried.
+func withContextTimeout() CallOption {
+	return func(o *CallOptions) {
+		o.ctxTimeout = true
+	}
+}
+
+// legacyOptions returns the options equivalent to the arguments of Do and Get.
+func legacyOptions(s Signer, isELSAPI bool) []CallOption {
+	opts := []CallOption{WithSigner(s), withContextTimeout()}
+	if !isELSAPI {
+		opts = append(opts, ThirdParty(""))
+	}
+	return opts
+}
+
+// DoWith makes a call with request r as Do does, with the settings given by
+// opts. By default, the request is not signed and is to the ELS API. Unlike
+// Do, ctx may have no deadline: the call is then limited by the caller's
+// default timeout, unless WithTimeout is used.
+func (a *EDAPICaller) DoWith(ctx context.Context, r *http.Request, opts ...CallOption) (*http.Response, error) {
+	return a.doWith(ctx, r, NewCallOptions(opts...), OpDo)
+}
+
+// GetWith makes a GET call to url as DoWith does.
+func (a *EDAPICaller) GetWith(ctx context.Context, url string, opts ...CallOption) (*http.Response, error) {
+	r, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return a.doWith(ctx, r, NewCallOptions(opts...), OpGet)
+}
+
+// doWith implements DoWith, reporting the call to observers as operation op.
+func (a *EDAPICaller) doWith(ctx context.Context, r *http.Request, o CallOptions, op string) (resp *http.Response, err error) {
+	if o.Service != "" {
+		svc, ok := a.getService(o.Service)
+		if !ok {
+			return nil, ErrUnknownService
+		}
+		o.applyService(svc)
+	}
+
+	cancel := func() {}
+	defer func() {
+		if err != nil || resp == nil {
+			cancel()
+			return
+		}
+		// Release the context once the caller has finished with the body.
+		resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	}()
+
+	if !o.ctxTimeout || ctx == nil {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		timeout := o.Timeout
+		if _, ok := ctx.Deadline(); !ok && timeout == 0 {
+			timeout = a.requestTimeout
+		}
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+		}
+	}
+
+	var c *Call
+	if o.Response != nil {
+		*o.Response = Response{}
+		ctx = context.WithValue(ctx, responseKey{}, o.Response)
+		start := time.Now()
+		defer func() {
+			if c != nil {
+				o.Response.record(c)
+			}
+			o.Response.Duration = time.Since(start)
+			o.Response.ClockOffset = a.ClockOffset()
+			o.Response.complete(resp)
+		}()
+	}
+
+	if err = o.apply(r); err != nil {
+		return nil, err
+	}
+	a.setIdempotencyKey(r, o.IsELSAPI, o.defaultIdempotencyKey)
+
+	// The call is reported to observers once, however many attempts it
+	// takes.
+	c = newCall(ctx, op, r, o.Signer, o.IsELSAPI)
+	ctx = a.callStarted(ctx, c)
+	defer func() {
+		a.callFinished(ctx, c, resp, err)
+	}()
+
+	if o.Retries <= 0 || dryRunFromContext(ctx) != nil {
+		return a.do(ctx, c, r, o)
+	}
+
+	for attempt := 1; ; attempt++ {
+		req := copyRequest(ctx, r)
+		if attempt > 1 && r.GetBody != nil {
+			if req.Body, err = r.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err = a.do(ctx, c, req, o)
+		statusCode := 0
+		if err == nil {
+			statusCode = resp.StatusCode
+		}
+		if attempt > o.Retries || !DefaultShouldRetry(statusCode, err) || !canResend(r) || ctx.Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		log.WithFields(log.Fields{"Time": time.Now(), "attempt": attempt, "idempotencyKey": r.Header.Get(HeaderIdempotencyKey), "err": err, "statusCode": statusCode}).Debug("ApiCaller: Retrying")
+		if err = sleep(ctx, o.RetryDelay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// apply applies the options which modify the request to r.
+func (o *CallOptions) apply(r *http.Request) error {
+	for k, vs := range o.Header {
+		r.Header[k] = append([]string(nil), vs...)
+	}
+	if o.IdempotencyKey != "" {
+		r.Header.Set(HeaderIdempotencyKey, o.IdempotencyKey)
+	}
+
+	if o.IsELSAPI || o.BaseURL == "" || r.URL.IsAbs() {
+		return nil
+	}
+	base, err := url.Parse(o.BaseURL)
+	if err != nil {
+		return err
+	}
+	u := *base
+	u.Path = strings.TrimSuffix(base.Path, "/") + r.URL.Path
+	u.RawPath = ""
+	if r.URL.RawPath != "" {
+		u.RawPath = strings.TrimSuffix(base.EscapedPath(), "/") + r.URL.RawPath
+	}
+	u.RawQuery = r.URL.RawQuery
+	r.URL = &u
+	r.Host = ""
+	return nil
+}
@@ -0,0 +1,172 @@
+package els
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Call Options Test Suite", func() {
+
+	var (
+		now, _  = time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+		server  *httptest.Server
+		sut     *EDAPICaller
+		signer  *APISigner
+		mu      sync.Mutex
+		reqs    []*http.Request
+		bodies  []string
+		delay   time.Duration
+		failing int
+	)
+
+	BeforeEach(func() {
+		reqs, bodies, delay, failing = nil, nil, 0, 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			mu.Lock()
+			reqs = append(reqs, r)
+			bodies = append(bodies, string(b))
+			fail := failing > 0
+			failing--
+			mu.Unlock()
+			time.Sleep(delay)
+			if fail {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			} else if r.URL.Path == "/1.0/large" {
+				w.Write(bytes.Repeat([]byte("x"), 10<<20))
+			}
+		}))
+		u, err := url.Parse(server.URL)
+		Expect(err).To(BeNil())
+
+		tp := datetime.NewNowTimeProvider()
+		tp.SetNow(now)
+		sut = NewEDAPICaller(&http.Client{}, tp, 50*time.Millisecond, "")
+		sut.APIHandler.Scheme = u.Scheme
+		sut.APIHandler.Domain = u.Host
+
+		signer, err = NewAPISigner(&AccessKey{ID: "id", SecretAccessKey: "secret"})
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("defaults to an unsigned call to the ELS API", func() {
+		o := NewCallOptions()
+		Expect(o.IsELSAPI).To(BeTrue())
+		Expect(o.Signer).To(BeNil())
+
+		rep, err := sut.GetWith(context.Background(), "/vendors/v1")
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(reqs[0].URL.Path).To(Equal("/1.0/vendors/v1"))
+		Expect(reqs[0].Header.Get("Authorization")).To(Equal(""))
+	})
+
+	It("signs the request and sets headers", func() {
+		rep, err := sut.GetWith(nil, "/vendors/v1",
+			WithSigner(signer),
+			WithHeaders(http.Header{"x-request-id": {"abc"}}),
+			WithIdempotencyKey("key"))
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(reqs[0].Header.Get("Authorization")).To(HavePrefix("ELS id:"))
+		Expect(reqs[0].Header.Get("X-Request-Id")).To(Equal("abc"))
+		Expect(reqs[0].Header.Get(HeaderIdempotencyKey)).To(Equal("key"))
+	})
+
+	It("prefixes the base URL of a third-party API", func() {
+		rep, err := sut.GetWith(nil, "/things?a=1", ThirdParty(server.URL+"/api/"))
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(reqs[0].URL.RequestURI()).To(Equal("/api/things?a=1"))
+	})
+
+	Describe("Timeouts", func() {
+		BeforeEach(func() {
+			delay = 200 * time.Millisecond
+		})
+
+		It("applies the default timeout to a context without a deadline", func() {
+			_, err := sut.GetWith(context.Background(), "/vendors/v1")
+			Expect(err).To(Equal(context.DeadlineExceeded))
+		})
+
+		It("applies the timeout given", func() {
+			rep, err := sut.GetWith(context.Background(), "/vendors/v1", WithTimeout(time.Second))
+			Expect(err).To(BeNil())
+			rep.Body.Close()
+		})
+
+		It("leaves the body of the response readable until it is closed", func() {
+			delay = 0
+			rep, err := sut.GetWith(context.Background(), "/large", WithTimeout(time.Second))
+			Expect(err).To(BeNil())
+			defer rep.Body.Close()
+			b, err := ioutil.ReadAll(rep.Body)
+			Expect(err).To(BeNil())
+			Expect(b).To(HaveLen(10 << 20))
+		})
+
+		It("is unchanged for Do, which applies no timeout to a context", func() {
+			rep, err := sut.Get(context.Background(), "/vendors/v1", nil, true)
+			Expect(err).To(BeNil())
+			rep.Body.Close()
+		})
+	})
+
+	Describe("WithRetry", func() {
+		BeforeEach(func() {
+			failing = 2
+		})
+
+		It("retries failed calls, resending the body", func() {
+			r, err := http.NewRequest("POST", "/vendors/v1", bytes.NewBufferString("body"))
+			Expect(err).To(BeNil())
+			rep, err := sut.DoWith(nil, r, WithSigner(signer), WithRetry(2, time.Millisecond))
+			Expect(err).To(BeNil())
+			rep.Body.Close()
+			Expect(rep.StatusCode).To(Equal(http.StatusOK))
+			Expect(bodies).To(Equal([]string{"body", "body", "body"}))
+			for _, req := range reqs {
+				Expect(req.URL.Path).To(Equal("/1.0/vendors/v1"))
+			}
+		})
+
+		It("reports the call to observers once, with each attempt", func() {
+			o := &recordingObserver{name: "o", events: &[]string{}}
+			sut.AddObserver(o)
+			rr := &Response{}
+			rep, err := sut.GetWith(nil, "/vendors/v1", WithRetry(2, 0), WithResponse(rr))
+			Expect(err).To(BeNil())
+			rep.Body.Close()
+			Expect(*o.events).To(Equal([]string{"o: started Get", "o: send", "o: send", "o: send", "o: finished Get"}))
+			Expect(o.finished).To(HaveLen(1))
+			Expect(o.finished[0].Attempts).To(Equal(3))
+			Expect(o.finished[0].AttemptDurations).To(HaveLen(3))
+			Expect(o.finished[0].StatusCode).To(Equal(http.StatusOK))
+			Expect(rr.Attempts).To(Equal(3))
+		})
+
+		It("returns the last response once the retries are exhausted", func() {
+			rep, err := sut.GetWith(nil, "/vendors/v1", WithRetry(1, 0))
+			Expect(err).To(BeNil())
+			rep.Body.Close()
+			Expect(rep.StatusCode).To(Equal(http.StatusServiceUnavailable))
+			Expect(reqs).To(HaveLen(2))
+		})
+	})
+})
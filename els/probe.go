@@ -0,0 +1,266 @@
+package els
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Defaults for the settings of a Probe.
+const (
+	DefaultProbeInterval         = 30 * time.Second
+	DefaultProbeTimeout          = 5 * time.Second
+	DefaultProbeFailureThreshold = 3
+	DefaultProbeWindow           = 20
+)
+
+// ProbeStats describes the recent results of a Probe.
+type ProbeStats struct {
+	Healthy bool `json:"healthy"`
+
+	// LastCheck is when the last check completed, and LastError the error it
+	// failed with, if any.
+	LastCheck time.Time `json:"lastCheck"`
+	LastError string    `json:"lastError,omitempty"`
+
+	// LastLatency is the duration of the last check, and MeanLatency the mean
+	// duration of the checks in the window.
+	LastLatency time.Duration `json:"lastLatency"`
+	MeanLatency time.Duration `json:"meanLatency"`
+
+	// SuccessRatio is the proportion of the checks in the window which
+	// succeeded.
+	SuccessRatio float64 `json:"successRatio"`
+
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+}
+
+// Probe checks the reachability of the ELS (or another API) by making a
+// lightweight call periodically, and tracks the results. A Probe is unhealthy
+// until a check succeeds, and becomes unhealthy again after FailureThreshold
+// consecutive failures. Probe implements http.Handler, so can be mounted as a
+// readiness check. Create one with NewProbe, adjust its settings, then Start
+// it.
+type Probe struct {
+	// Interval is the time between checks. If it is not positive,
+	// DefaultProbeInterval is used.
+	Interval time.Duration
+
+	// Timeout limits each check. If it is not positive, DefaultProbeTimeout
+	// is used.
+	Timeout time.Duration
+
+	// FailureThreshold is the number of consecutive failures after which the
+	// probe becomes unhealthy.
+	FailureThreshold int
+
+	// Window is the number of recent checks used to calculate the success
+	// ratio and mean latency.
+	Window int
+
+	// Success determines whether a response with the given status code means
+	// the check succeeded. By default, any status code below 500 does, since
+	// it shows the API is reachable.
+	Success func(statusCode int) bool
+
+	caller   APICaller
+	path     string
+	signer   Signer
+	isELSAPI bool
+
+	mu        sync.RWMutex
+	stats     ProbeStats
+	results   []probeResult
+	listeners []func(healthy bool)
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewProbe returns a Probe which checks by GETting path with a, signed by s if
+// it is not nil. isELSAPI is as for APICaller.Get. Checks bypass the cache and
+// coalescing (see WithoutCache), so that each reaches the server.
+func NewProbe(a APICaller, path string, s Signer, isELSAPI bool) *Probe {
+	return &Probe{
+		Interval:         DefaultProbeInterval,
+		Timeout:          DefaultProbeTimeout,
+		FailureThreshold: DefaultProbeFailureThreshold,
+		Window:           DefaultProbeWindow,
+		Success:          func(statusCode int) bool { return statusCode < 500 },
+		caller:           a,
+		path:             path,
+		signer:           s,
+		isELSAPI:         isELSAPI,
+	}
+}
+
+// OnChange registers a function to be called (synchronously, from the
+// goroutine making checks) whenever the probe becomes healthy or unhealthy.
+func (p *Probe) OnChange(f func(healthy bool)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listeners = append(p.listeners, f)
+}
+
+// Start makes a check immediately and then every Interval until Stop is
+// called.
+func (p *Probe) Start() {
+	p.mu.Lock()
+	if p.stop != nil {
+		p.mu.Unlock()
+		return
+	}
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	p.stop, p.stopped = stop, stopped
+	p.mu.Unlock()
+
+	interval := p.Interval
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+
+	go func() {
+		defer close(stopped)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			p.Check(nil)
+			select {
+			case <-t.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the checks started by Start, waiting for any in progress to
+// complete.
+func (p *Probe) Stop() {
+	p.mu.Lock()
+	stop, stopped := p.stop, p.stopped
+	p.stop, p.stopped = nil, nil
+	p.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-stopped
+	}
+}
+
+// Check makes a check now, records its result and returns its error, if any.
+// Pass nil as ctx to apply Timeout.
+func (p *Probe) Check(ctx context.Context) error {
+	if ctx == nil {
+		timeout := p.Timeout
+		if timeout <= 0 {
+			timeout = DefaultProbeTimeout
+		}
+		var cancel func()
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+	}
+
+	r, err := http.NewRequest("GET", p.path, nil)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	resp, err := p.caller.DoWith(ctx, r, append(legacyOptions(p.signer, p.isELSAPI), WithoutCache())...)
+	if err == nil {
+		resp.Body.Close()
+		if !p.Success(resp.StatusCode) {
+			err = ErrUnexpectedStatusCode
+		}
+	}
+
+	p.record(time.Since(start), err)
+	return err
+}
+
+// record records the result of a check, notifying the listeners if the health
+// of the probe changed.
+func (p *Probe) record(latency time.Duration, err error) {
+	p.mu.Lock()
+
+	s := &p.stats
+	s.LastCheck = time.Now()
+	s.LastLatency = latency
+	s.LastError = ""
+	if err != nil {
+		s.LastError = err.Error()
+		s.ConsecutiveFailures++
+	} else {
+		s.ConsecutiveFailures = 0
+	}
+
+	p.results = append(p.results, probeResult{ok: err == nil, latency: latency})
+	if p.Window > 0 && len(p.results) > p.Window {
+		p.results = p.results[len(p.results)-p.Window:]
+	}
+
+	ok, total := 0, time.Duration(0)
+	for _, r := range p.results {
+		if r.ok {
+			ok++
+		}
+		total += r.latency
+	}
+	s.SuccessRatio = float64(ok) / float64(len(p.results))
+	s.MeanLatency = total / time.Duration(len(p.results))
+
+	was := s.Healthy
+	if err == nil {
+		s.Healthy = true
+	} else if s.ConsecutiveFailures >= p.FailureThreshold {
+		s.Healthy = false
+	}
+
+	changed, healthy := was != s.Healthy, s.Healthy
+	listeners := p.listeners
+	p.mu.Unlock()
+
+	if changed {
+		for _, f := range listeners {
+			f(healthy)
+		}
+	}
+}
+
+// probeResult is the result of a check.
+type probeResult struct {
+	ok      bool
+	latency time.Duration
+}
+
+// Healthy returns true if the probe is healthy.
+func (p *Probe) Healthy() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.stats.Healthy
+}
+
+// Stats returns the recent results of the probe.
+func (p *Probe) Stats() ProbeStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.stats
+}
+
+// ServeHTTP implements interface http.Handler, responding with the probe's
+// stats as JSON and a status of 200 if it is healthy or 503 if not.
+func (p *Probe) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s := p.Stats()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if s.Healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(s)
+}
@@ -0,0 +1,147 @@
+package els
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Probe Test Suite", func() {
+
+	var (
+		caller     *EDAPICaller
+		server     *httptest.Server
+		statusCode int32
+		hits       int32
+		sut        *Probe
+
+		mu      sync.Mutex
+		changes []bool
+	)
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&statusCode, http.StatusOK)
+		atomic.StoreInt32(&hits, 0)
+		changes = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(int(atomic.LoadInt32(&statusCode)))
+		}))
+		u, perr := url.Parse(server.URL)
+		Expect(perr).To(BeNil())
+
+		caller = NewEDAPICaller(&http.Client{}, datetime.NewNowTimeProvider(), time.Second, "")
+		caller.APIHandler.Scheme = u.Scheme
+		caller.APIHandler.Domain = u.Host
+
+		sut = NewProbe(caller, "/ping", nil, true)
+		sut.FailureThreshold = 2
+		sut.Window = 4
+		sut.OnChange(func(healthy bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			changes = append(changes, healthy)
+		})
+	})
+
+	AfterEach(func() {
+		sut.Stop()
+		server.Close()
+	})
+
+	It("is unhealthy until a check succeeds", func() {
+		Expect(sut.Healthy()).To(BeFalse())
+		Expect(sut.Check(nil)).To(Succeed())
+		Expect(sut.Healthy()).To(BeTrue())
+		Expect(changes).To(Equal([]bool{true}))
+	})
+
+	It("becomes unhealthy after consecutive failures", func() {
+		sut.Check(nil)
+		atomic.StoreInt32(&statusCode, http.StatusBadGateway)
+
+		Expect(sut.Check(nil)).To(Equal(ErrUnexpectedStatusCode))
+		Expect(sut.Healthy()).To(BeTrue())
+		sut.Check(nil)
+		Expect(sut.Healthy()).To(BeFalse())
+		Expect(changes).To(Equal([]bool{true, false}))
+
+		s := sut.Stats()
+		Expect(s.ConsecutiveFailures).To(Equal(2))
+		Expect(s.SuccessRatio).To(Equal(1.0 / 3))
+		Expect(s.LastError).To(Equal(ErrUnexpectedStatusCode.Error()))
+	})
+
+	It("counts 4xx responses as reachable", func() {
+		atomic.StoreInt32(&statusCode, http.StatusUnauthorized)
+		Expect(sut.Check(nil)).To(Succeed())
+	})
+
+	It("counts connection failures as failures", func() {
+		server.Close()
+		Expect(sut.Check(nil)).NotTo(Succeed())
+		Expect(sut.Stats().ConsecutiveFailures).To(Equal(1))
+	})
+
+	It("checks periodically once started", func() {
+		sut.Interval = 5 * time.Millisecond
+		sut.Start()
+		Eventually(sut.Healthy).Should(BeTrue())
+		atomic.StoreInt32(&statusCode, http.StatusInternalServerError)
+		Eventually(sut.Healthy).Should(BeFalse())
+		sut.Stop()
+		Expect(sut.Stats().SuccessRatio).To(BeNumerically("<", 1))
+	})
+
+	It("checks with the default timeout if Timeout is not positive", func() {
+		sut.Timeout = 0
+		Expect(sut.Check(nil)).To(Succeed())
+	})
+
+	It("checks with the default interval if Interval is not positive", func() {
+		sut.Interval = 0
+		sut.Start()
+		Eventually(sut.Healthy).Should(BeTrue())
+	})
+
+	It("bypasses the cache and coalescing", func() {
+		caller.SetCache(NewMemoryCache(0))
+		caller.SetCoalescing(true)
+
+		Expect(sut.Check(nil)).To(Succeed())
+		Expect(sut.Check(nil)).To(Succeed())
+		Expect(atomic.LoadInt32(&hits)).To(Equal(int32(2)))
+
+		resp, err := caller.Get(nil, "/ping", nil, true)
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+		Expect(atomic.LoadInt32(&hits)).To(Equal(int32(3)))
+	})
+
+	Describe("ServeHTTP", func() {
+		It("reports the probe's health and stats", func() {
+			w := httptest.NewRecorder()
+			sut.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+			Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+
+			sut.Check(nil)
+			w = httptest.NewRecorder()
+			sut.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+			Expect(w.Code).To(Equal(http.StatusOK))
+			s := ProbeStats{}
+			Expect(json.Unmarshal(w.Body.Bytes(), &s)).To(Succeed())
+			Expect(s.Healthy).To(BeTrue())
+			Expect(s.SuccessRatio).To(Equal(1.0))
+		})
+	})
+})
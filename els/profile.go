@@ -0,0 +1,175 @@
+package els
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+)
+
+const (
+	// DefaultProfileName is the name of the profile used when none is
+	// specified.
+	DefaultProfileName = "default"
+
+	// DefaultRequestTimeout is the timeout applied to API calls made through a
+	// Profile which doesn't specify its own.
+	DefaultRequestTimeout = 30 * time.Second
+)
+
+// Errors which may be returned when loading or using profiles.
+var (
+	ErrNoProfile = errors.New("No Such Profile")
+)
+
+// Profile holds everything needed to make ELS API calls on behalf of a single
+// user: the access key used to sign requests and the API endpoint to which
+// they are sent. Leave the endpoint fields blank to use the defaults.
+type Profile struct {
+	// AccessKey is used to sign requests made with this profile. It may be
+	// nil if the profile has not yet been given a key.
+	AccessKey *AccessKey `json:"accessKey,omitempty"`
+
+	// Scheme overrides DefaultAPIScheme.
+	Scheme string `json:"scheme,omitempty"`
+
+	// Domain overrides DefaultAPIDomain.
+	Domain string `json:"domain,omitempty"`
+
+	// Version overrides DefaultAPIVersion.
+	Version string `json:"version,omitempty"`
+
+	// TimeoutSecs overrides DefaultRequestTimeout.
+	TimeoutSecs uint `json:"timeoutSecs,omitempty"`
+
+	// SignatureVersion selects the signing scheme: 2 for SchemeV2, otherwise
+	// SchemeV1.
+	SignatureVersion uint `json:"signatureVersion,omitempty"`
+
+	// SignedHeaders lists additional headers to sign when using SchemeV2.
+	SignedHeaders []string `json:"signedHeaders,omitempty"`
+}
+
+// Signer returns an APISigner which signs requests with the profile's access
+// key using the profile's signing scheme, to the profile's API version (see
+// APISigner.SetAPIVersions).
+func (p *Profile) Signer() (s *APISigner, err error) {
+	if p.SignatureVersion == 2 {
+		s, err = NewAPISignerV2(p.AccessKey, p.SignedHeaders...)
+	} else {
+		s, err = NewAPISigner(p.AccessKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	v := p.Version
+	if v == "" {
+		v = DefaultAPIVersion
+	}
+	s.SetAPIVersions(v)
+
+	return s, nil
+}
+
+// Timeout returns the request timeout to use for API calls made with this
+// profile.
+func (p *Profile) Timeout() time.Duration {
+	if p.TimeoutSecs == 0 {
+		return DefaultRequestTimeout
+	}
+	return time.Duration(p.TimeoutSecs) * time.Second
+}
+
+// NewAPICaller returns an EDAPICaller configured to call the endpoint defined
+// by the profile. Pass nil for c to use http.DefaultClient.
+func (p *Profile) NewAPICaller(c *http.Client, tp datetime.TimeProvider) *EDAPICaller {
+	a := NewEDAPICaller(c, tp, p.Timeout(), p.Version)
+
+	if p.Scheme != "" {
+		a.APIHandler.Scheme = p.Scheme
+	}
+	if p.Domain != "" {
+		a.APIHandler.Domain = p.Domain
+	}
+
+	return a
+}
+
+// ProfileConfig is a named collection of profiles, typically persisted as a
+// JSON file in the user's home directory.
+type ProfileConfig struct {
+	// Profiles maps a profile name to its configuration.
+	Profiles map[string]*Profile `json:"profiles"`
+}
+
+// DefaultProfileConfigPath returns the location of the profile config file
+// used when no other is specified: $HOME/.els/config.json.
+func DefaultProfileConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return filepath.Join(home, ".els", "config.json")
+}
+
+// LoadProfileConfig reads a ProfileConfig from the JSON file at path. If the
+// file does not exist, an empty config is returned so that it can be populated
+// and saved.
+func LoadProfileConfig(path string) (*ProfileConfig, error) {
+	c := &ProfileConfig{Profiles: map[string]*Profile{}}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(content, c); err != nil {
+		return nil, err
+	}
+
+	if c.Profiles == nil {
+		c.Profiles = map[string]*Profile{}
+	}
+
+	return c, nil
+}
+
+// Save writes the config as JSON to the file at path, creating the directory
+// if required. The file is only readable by its owner as it contains secret
+// access keys.
+func (c *ProfileConfig) Save(path string) error {
+	content, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, content, 0600)
+}
+
+// Profile returns the named profile, or ErrNoProfile if it doesn't exist. Pass
+// "" to get the default profile.
+func (c *ProfileConfig) Profile(name string) (*Profile, error) {
+	if name == "" {
+		name = DefaultProfileName
+	}
+
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, ErrNoProfile
+	}
+
+	return p, nil
+}
@@ -0,0 +1,172 @@
+package els
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Profile Test Suite", func() {
+
+	var (
+		dir  string
+		path string
+		sut  *ProfileConfig
+		p    *Profile
+		err  error
+		k    = &AccessKey{
+			ID:              AccessKeyID("id"),
+			SecretAccessKey: SecretAccessKey("sac"),
+			Email:           "example@test.com",
+		}
+	)
+
+	BeforeEach(func() {
+		dir, err = ioutil.TempDir("", "els-profile")
+		Expect(err).To(BeNil())
+		path = filepath.Join(dir, "sub", "config.json")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("LoadProfileConfig", func() {
+		JustBeforeEach(func() {
+			sut, err = LoadProfileConfig(path)
+		})
+
+		Context("The file does not exist", func() {
+			It("returns an empty config", func() {
+				Expect(err).To(BeNil())
+				Expect(sut.Profiles).To(BeEmpty())
+			})
+		})
+
+		Context("The file was previously saved", func() {
+			BeforeEach(func() {
+				c := &ProfileConfig{Profiles: map[string]*Profile{
+					"default": {AccessKey: k, Domain: "localhost:8080", TimeoutSecs: 5},
+				}}
+				Expect(c.Save(path)).To(BeNil())
+			})
+			It("restores the profiles", func() {
+				Expect(err).To(BeNil())
+				p, err = sut.Profile("")
+				Expect(err).To(BeNil())
+				Expect(p.AccessKey.ID).To(Equal(k.ID))
+				Expect(p.AccessKey.SecretAccessKey).To(Equal(k.SecretAccessKey))
+				Expect(p.Domain).To(Equal("localhost:8080"))
+				Expect(p.Timeout()).To(Equal(5 * time.Second))
+			})
+			It("is only readable by its owner", func() {
+				fi, serr := os.Stat(path)
+				Expect(serr).To(BeNil())
+				Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0600)))
+			})
+		})
+
+		Context("The file is not valid JSON", func() {
+			BeforeEach(func() {
+				Expect(os.MkdirAll(filepath.Dir(path), 0700)).To(BeNil())
+				Expect(ioutil.WriteFile(path, []byte("{"), 0600)).To(BeNil())
+			})
+			It("returns an error", func() {
+				Expect(err).NotTo(BeNil())
+			})
+		})
+	})
+
+	Describe("ProfileConfig", func() {
+		Describe("Profile", func() {
+			BeforeEach(func() {
+				sut = &ProfileConfig{Profiles: map[string]*Profile{}}
+			})
+			It("returns ErrNoProfile for an unknown profile", func() {
+				_, err = sut.Profile("unknown")
+				Expect(err).To(Equal(ErrNoProfile))
+			})
+		})
+	})
+
+	Describe("Profile", func() {
+		BeforeEach(func() {
+			p = &Profile{AccessKey: k}
+		})
+
+		Describe("Timeout", func() {
+			It("defaults to DefaultRequestTimeout", func() {
+				Expect(p.Timeout()).To(Equal(DefaultRequestTimeout))
+			})
+		})
+
+		Describe("Signer", func() {
+			It("returns a signer for the access key", func() {
+				s, serr := p.Signer()
+				Expect(serr).To(BeNil())
+				Expect(s.accessKey).To(Equal(k))
+				Expect(s.Scheme()).To(Equal(SchemeV1))
+				Expect(s.APIVersions()).To(Equal([]string{DefaultAPIVersion}))
+			})
+			Context("The profile uses another API version", func() {
+				BeforeEach(func() {
+					p.Version = "2.0"
+				})
+				It("restricts the signer to that version", func() {
+					s, serr := p.Signer()
+					Expect(serr).To(BeNil())
+					Expect(s.APIVersions()).To(Equal([]string{"2.0"}))
+				})
+			})
+			Context("The profile uses signature version 2", func() {
+				BeforeEach(func() {
+					p.SignatureVersion = 2
+					p.SignedHeaders = []string{"X-Request-Id"}
+				})
+				It("returns a SchemeV2 signer", func() {
+					s, serr := p.Signer()
+					Expect(serr).To(BeNil())
+					Expect(s.Scheme()).To(Equal(SchemeV2))
+					Expect(s.signedHeaders).To(ContainElement("x-request-id"))
+				})
+			})
+		})
+
+		Describe("NewAPICaller", func() {
+			var a *EDAPICaller
+
+			JustBeforeEach(func() {
+				a = p.NewAPICaller(nil, datetime.NewNowTimeProvider())
+			})
+
+			Context("The endpoint is not overridden", func() {
+				It("uses the defaults", func() {
+					Expect(a.APIHandler.Scheme).To(Equal(DefaultAPIScheme))
+					Expect(a.APIHandler.Domain).To(Equal(DefaultAPIDomain))
+					Expect(a.APIHandler.Version).To(Equal(DefaultAPIVersion))
+					Expect(a.requestTimeout).To(Equal(DefaultRequestTimeout))
+				})
+			})
+
+			Context("The endpoint is overridden", func() {
+				BeforeEach(func() {
+					p.Scheme = "http"
+					p.Domain = "localhost:8080"
+					p.Version = "1.2"
+					p.TimeoutSecs = 2
+				})
+				It("uses the profile's endpoint", func() {
+					Expect(a.APIHandler.Scheme).To(Equal("http"))
+					Expect(a.APIHandler.Domain).To(Equal("localhost:8080"))
+					Expect(a.APIHandler.Version).To(Equal("1.2"))
+					Expect(a.requestTimeout).To(Equal(2 * time.Second))
+				})
+			})
+		})
+	})
+})
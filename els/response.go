@@ -0,0 +1,228 @@
+package els
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// HeaderRequestID is the header in which the server identifies its handling of
+// a request.
+const HeaderRequestID = "X-Request-Id"
+
+// maxAPIErrorBody is the most of the body of an error response which is read
+// into an APIError.
+const maxAPIErrorBody = 64 << 10
+
+// Response is the response to a call, with metadata describing how it was
+// obtained. Get one by passing WithResponse to DoWith or GetWith, or from
+// DoJSON and GetJSON.
+type Response struct {
+	// Response is the final response, or nil if none was received.
+	*http.Response
+
+	// SignedRequest is the final request sent, as signed.
+	SignedRequest *http.Request
+
+	// Attempts is the number of times the request was sent, including
+	// retries, re-signing after clock skew was detected and failover.
+	Attempts int
+
+	// Duration is how long the whole call took, and AttemptDurations how
+	// long each attempt took to get a response or fail.
+	Duration         time.Duration
+	AttemptDurations []time.Duration
+
+	// RequestID is the ID given to the request by the server in the
+	// X-Request-Id header of the response, if any.
+	RequestID string
+
+	// ClockOffset is the estimate of the offset of the ELS clock after the
+	// call (see EDAPICaller.ClockOffset).
+	ClockOffset time.Duration
+
+	// FromCache is true if the response was taken from the cache (see
+	// EDAPICaller.SetCache) without sending the request.
+	FromCache bool
+
+	// Coalesced is true if the response was shared with an identical
+	// concurrent call (see EDAPICaller.SetCoalescing). SignedRequest,
+	// Attempts and AttemptDurations are then unknown.
+	Coalesced bool
+
+	// APIError is the error decoded from the body of the response if its
+	// status code is 400 or more.
+	APIError *APIError
+}
+
+// APIError is an error response from an API.
+type APIError struct {
+	// StatusCode is the status code of the response.
+	StatusCode int
+
+	// Code and Message describe the error, if the body of the response is
+	// JSON holding them. If there is no message, Message is the text of the
+	// status code.
+	Code    string
+	Message string
+
+	// RequestID is the X-Request-Id header of the response, if any.
+	RequestID string
+
+	// Body is the body of the response (up to 64KB).
+	Body []byte
+}
+
+// Error implements interface error.
+func (e *APIError) Error() string {
+	s := fmt.Sprintf("API Error %d: %s", e.StatusCode, e.Message)
+	if e.Code != "" {
+		s += " (" + e.Code + ")"
+	}
+	if e.RequestID != "" {
+		s += ", request " + e.RequestID
+	}
+	return s
+}
+
+// apiErrorBody is the JSON body of an error response. ELS errors have a code
+// and message; other APIs (and elstest) may give just an error.
+type apiErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Error   string `json:"error"`
+}
+
+// decodeAPIError returns the error described by resp, or nil if its status
+// code is less than 400. The body of resp is read, and replaced so that it can
+// be read again.
+func decodeAPIError(resp *http.Response) *APIError {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+
+	e := &APIError{StatusCode: resp.StatusCode, RequestID: resp.Header.Get(HeaderRequestID)}
+
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxAPIErrorBody))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), resp.Body), resp.Body}
+	e.Body = b
+
+	eb := apiErrorBody{}
+	if json.Unmarshal(b, &eb) == nil {
+		e.Code = eb.Code
+		e.Message = eb.Message
+		if e.Message == "" {
+			e.Message = eb.Error
+		}
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+
+	return e
+}
+
+// WithResponse fills r with the response to the call and its metadata when the
+// call returns. The body of r.Response is that returned by the call, so close
+// it only once.
+func WithResponse(r *Response) CallOption {
+	return func(o *CallOptions) {
+		o.Response = r
+	}
+}
+
+// responseKey is the context key under which the Response of a call is stored
+// while it is being made.
+type responseKey struct{}
+
+// responseFromContext returns the Response being recorded for the call made
+// with ctx, or nil.
+func responseFromContext(ctx context.Context) *Response {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(responseKey{}).(*Response)
+	return r
+}
+
+// record sets the attempts of call c in r.
+func (r *Response) record(c *Call) {
+	r.Attempts = c.Attempts
+	r.AttemptDurations = c.AttemptDurations
+	r.FromCache = c.FromCache
+}
+
+// complete sets the final response of r, and the metadata derived from it.
+func (r *Response) complete(resp *http.Response) {
+	r.Response = resp
+	if resp == nil {
+		return
+	}
+	r.RequestID = resp.Header.Get(HeaderRequestID)
+	r.APIError = decodeAPIError(resp)
+}
+
+// NewJSONRequest returns a request whose body is v encoded as JSON. The body
+// can be read again (see http.Request.GetBody), so the request can be retried.
+func NewJSONRequest(method string, url string, v interface{}) (*http.Request, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := http.NewRequest(method, url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", RequiredContentType)
+
+	return r, nil
+}
+
+// DoJSON makes a call with request r using a.DoWith, and decodes the JSON body
+// of a successful response into v, unless v is nil. If the status code of the
+// response is 400 or more, the returned error is its *APIError. The body of the
+// response has been read and closed; the Response describes the call.
+func DoJSON(ctx context.Context, a APICaller, r *http.Request, v interface{}, opts ...CallOption) (*Response, error) {
+	rr := &Response{}
+	resp, err := a.DoWith(ctx, r, append(append([]CallOption(nil), opts...), WithResponse(rr))...)
+	if rr.Response == nil && resp != nil {
+		// a did not record the response, e.g. it is a mock.APICaller.
+		rr.complete(resp)
+	}
+	if err != nil {
+		return rr, err
+	}
+	defer resp.Body.Close()
+
+	if rr.APIError != nil {
+		return rr, rr.APIError
+	}
+	if v == nil {
+		return rr, nil
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return rr, err
+	}
+	return rr, json.Unmarshal(b, v)
+}
+
+// GetJSON makes a GET call to url as DoJSON does.
+func GetJSON(ctx context.Context, a APICaller, url string, v interface{}, opts ...CallOption) (*Response, error) {
+	r, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return DoJSON(ctx, a, r, v, opts...)
+}
@@ -0,0 +1,184 @@
+package els
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Response Test Suite", func() {
+
+	type vendor struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+
+	var (
+		now, _  = time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+		server  *httptest.Server
+		sut     *EDAPICaller
+		signer  *APISigner
+		mu      sync.Mutex
+		bodies  []string
+		failing int
+	)
+
+	BeforeEach(func() {
+		bodies, failing = nil, 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			mu.Lock()
+			bodies = append(bodies, string(b))
+			fail := failing > 0
+			failing--
+			mu.Unlock()
+
+			w.Header().Set(HeaderRequestID, "req-1")
+			switch {
+			case fail:
+				w.WriteHeader(http.StatusServiceUnavailable)
+			case r.URL.Path == "/1.0/vendors/missing":
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"code":"NotFound","message":"No such vendor"}`))
+			case r.URL.Path == "/1.0/large":
+				w.Write([]byte(strings.Repeat("x", 4<<20)))
+			default:
+				w.Write([]byte(`{"id":"v1","name":"Vendor"}`))
+			}
+		}))
+		u, err := url.Parse(server.URL)
+		Expect(err).To(BeNil())
+
+		tp := datetime.NewNowTimeProvider()
+		tp.SetNow(now)
+		sut = NewEDAPICaller(&http.Client{}, tp, time.Second, "")
+		sut.APIHandler.Scheme = u.Scheme
+		sut.APIHandler.Domain = u.Host
+
+		signer, err = NewAPISigner(&AccessKey{ID: "id", SecretAccessKey: "secret"})
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("WithResponse", func() {
+		It("records the metadata of the call", func() {
+			failing = 1
+			var rr Response
+			resp, err := sut.GetWith(nil, "/vendors/v1", WithSigner(signer), WithRetry(1, 0), WithResponse(&rr))
+			Expect(err).To(BeNil())
+			defer resp.Body.Close()
+
+			Expect(rr.Response).To(Equal(resp))
+			Expect(rr.StatusCode).To(Equal(http.StatusOK))
+			Expect(rr.Attempts).To(Equal(2))
+			Expect(rr.AttemptDurations).To(HaveLen(2))
+			Expect(rr.Duration).To(BeNumerically(">=", rr.AttemptDurations[0]+rr.AttemptDurations[1]))
+			Expect(rr.RequestID).To(Equal("req-1"))
+			Expect(rr.SignedRequest.Header.Get("Authorization")).To(HavePrefix("ELS id:"))
+			Expect(rr.SignedRequest.URL.Path).To(Equal("/1.0/vendors/v1"))
+			Expect(rr.FromCache).To(BeFalse())
+			Expect(rr.APIError).To(BeNil())
+		})
+
+		It("records a cache hit", func() {
+			sut.SetCache(NewMemoryCache(10))
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", "max-age=60")
+				w.Write([]byte(`{}`))
+			})
+			resp, err := sut.GetWith(nil, "/vendors/v1")
+			Expect(err).To(BeNil())
+			resp.Body.Close()
+
+			var rr Response
+			resp, err = sut.GetWith(nil, "/vendors/v1", WithResponse(&rr))
+			Expect(err).To(BeNil())
+			resp.Body.Close()
+			Expect(rr.FromCache).To(BeTrue())
+			Expect(rr.Attempts).To(Equal(0))
+			Expect(rr.SignedRequest).To(BeNil())
+		})
+
+		It("decodes an API error, leaving the body to be read", func() {
+			var rr Response
+			resp, err := sut.Get(nil, "/vendors/missing", nil, true)
+			Expect(err).To(BeNil())
+			resp.Body.Close()
+
+			resp, err = sut.GetWith(nil, "/vendors/missing", WithResponse(&rr))
+			Expect(err).To(BeNil())
+			defer resp.Body.Close()
+			Expect(rr.APIError).To(Equal(&APIError{
+				StatusCode: http.StatusNotFound,
+				Code:       "NotFound",
+				Message:    "No such vendor",
+				RequestID:  "req-1",
+				Body:       []byte(`{"code":"NotFound","message":"No such vendor"}`),
+			}))
+			Expect(rr.APIError.Error()).To(Equal("API Error 404: No such vendor (NotFound), request req-1"))
+			b, err := ioutil.ReadAll(resp.Body)
+			Expect(err).To(BeNil())
+			Expect(string(b)).To(Equal(string(rr.APIError.Body)))
+		})
+
+		It("leaves the body readable after the call returns", func() {
+			resp, err := sut.GetWith(nil, "/large", WithResponse(&Response{}))
+			Expect(err).To(BeNil())
+			defer resp.Body.Close()
+			b, err := ioutil.ReadAll(resp.Body)
+			Expect(err).To(BeNil())
+			Expect(b).To(HaveLen(4 << 20))
+		})
+	})
+
+	Describe("GetJSON", func() {
+		It("decodes the response", func() {
+			v := vendor{}
+			rr, err := GetJSON(context.Background(), sut, "/vendors/v1", &v, WithSigner(signer))
+			Expect(err).To(BeNil())
+			Expect(v).To(Equal(vendor{ID: "v1", Name: "Vendor"}))
+			Expect(rr.Attempts).To(Equal(1))
+			Expect(rr.RequestID).To(Equal("req-1"))
+		})
+
+		It("returns an API error", func() {
+			v := vendor{}
+			rr, err := GetJSON(nil, sut, "/vendors/missing", &v)
+			Expect(err).To(Equal(rr.APIError))
+			Expect(rr.APIError.Code).To(Equal("NotFound"))
+		})
+	})
+
+	Describe("DoJSON", func() {
+		It("sends a JSON request which can be retried", func() {
+			failing = 1
+			r, err := NewJSONRequest("POST", "/vendors", vendor{Name: "New"})
+			Expect(err).To(BeNil())
+			v := vendor{}
+			rr, err := DoJSON(nil, sut, r, &v, WithSigner(signer), WithRetry(1, 0))
+			Expect(err).To(BeNil())
+			Expect(rr.Attempts).To(Equal(2))
+			Expect(v.ID).To(Equal("v1"))
+
+			Expect(bodies).To(HaveLen(2))
+			sent := vendor{}
+			Expect(json.Unmarshal([]byte(bodies[1]), &sent)).To(Succeed())
+			Expect(sent.Name).To(Equal("New"))
+			Expect(rr.SignedRequest.Header.Get("Content-Type")).To(Equal(RequiredContentType))
+		})
+	})
+})
@@ -0,0 +1,235 @@
+package els
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidRouteTemplate = errors.New("Invalid Route Template")
+	ErrMissingRouteVar      = errors.New("Missing Route Variable")
+	ErrInvalidQuery         = errors.New("Invalid Query")
+)
+
+// RouteBuilder builds the route of an ELS API call (relative to the API
+// version, as passed to EDAPICaller.Do) from a template such as
+// "/users/{email}/accessKeys", escaping the value of each variable as a path
+// segment so that values containing e.g. '/', '+' or '?' cannot alter the
+// route. Create one with NewRoute. Errors are reported by Build.
+type RouteBuilder struct {
+	template string
+	vars     map[string]string
+	query    url.Values
+	err      error
+}
+
+// NewRoute returns a RouteBuilder for the given template, in which each
+// variable is a name in braces occupying a whole path segment.
+func NewRoute(template string) *RouteBuilder {
+	return &RouteBuilder{
+		template: template,
+		vars:     map[string]string{},
+		query:    url.Values{},
+	}
+}
+
+// Set sets the value of the variable with the given name.
+func (b *RouteBuilder) Set(name string, value string) *RouteBuilder {
+	b.vars[name] = value
+	return b
+}
+
+// Query adds query parameters taken from q, which may be url.Values, a
+// map[string]string or a struct (or pointer to one). The parameters of a
+// struct are its exported fields, named by their `url` tag if they have one.
+// The tag may be "-" to skip the field, and may be followed by the options
+// "omitempty", to omit the zero value, and "int", to encode a bool as 1 or 0.
+// Fields may be strings, bools, numbers, time.Times (encoded as RFC3339),
+// pointers to them (omitted if nil) or slices of them (one parameter per
+// element).
+func (b *RouteBuilder) Query(q interface{}) *RouteBuilder {
+	if b.err == nil {
+		b.err = addQuery(b.query, q)
+	}
+	return b
+}
+
+// Build returns the route with its path escaped and its query string encoded.
+func (b *RouteBuilder) Build() (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+	if !strings.HasPrefix(b.template, "/") {
+		return "", ErrInvalidRouteTemplate
+	}
+
+	segs := strings.Split(b.template[1:], "/")
+	for i, s := range segs {
+		if !strings.ContainsAny(s, "{}") {
+			continue
+		}
+		if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") || strings.Count(s, "{")+strings.Count(s, "}") != 2 {
+			return "", ErrInvalidRouteTemplate
+		}
+		v, ok := b.vars[s[1:len(s)-1]]
+		if !ok {
+			return "", ErrMissingRouteVar
+		}
+		segs[i] = escapeSegment(v)
+	}
+
+	route := "/" + strings.Join(segs, "/")
+	if len(b.query) > 0 {
+		route += "?" + b.query.Encode()
+	}
+	return route, nil
+}
+
+// NewRequest returns a request with the given method and body for the route
+// returned by Build. Its context carries the template of the route (see
+// WithRoute), so that observers report calls made with it by route template.
+func (b *RouteBuilder) NewRequest(method string, body io.Reader) (*http.Request, error) {
+	route, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	r, err := http.NewRequest(method, route, body)
+	if err != nil {
+		return nil, err
+	}
+	return r.WithContext(WithRoute(r.Context(), b.template)), nil
+}
+
+// escapeSegment escapes s for use as a single path segment. '+' is escaped as
+// well, as some servers decode it as a space.
+func escapeSegment(s string) string {
+	return strings.Replace(url.PathEscape(s), "+", "%2B", -1)
+}
+
+// addQuery adds the query parameters taken from q to vs (see
+// RouteBuilder.Query).
+func addQuery(vs url.Values, q interface{}) error {
+	switch q := q.(type) {
+	case nil:
+		return nil
+	case url.Values:
+		for k, v := range q {
+			vs[k] = append(vs[k], v...)
+		}
+		return nil
+	case map[string]string:
+		for k, v := range q {
+			vs.Add(k, v)
+		}
+		return nil
+	}
+
+	v := reflect.ValueOf(q)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ErrInvalidQuery
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name, opts := f.Name, ""
+		if tag, ok := f.Tag.Lookup("url"); ok {
+			if tag == "-" {
+				continue
+			}
+			if j := strings.Index(tag, ","); j >= 0 {
+				tag, opts = tag[:j], tag[j:]
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		omitEmpty := strings.Contains(opts, ",omitempty")
+		asInt := strings.Contains(opts, ",int")
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+
+		if fv.Kind() == reflect.Slice {
+			if omitEmpty && fv.Len() == 0 {
+				continue
+			}
+			for j := 0; j < fv.Len(); j++ {
+				s, err := queryValue(fv.Index(j), asInt)
+				if err != nil {
+					return err
+				}
+				vs.Add(name, s)
+			}
+			continue
+		}
+
+		if omitEmpty && isZero(fv) {
+			continue
+		}
+		s, err := queryValue(fv, asInt)
+		if err != nil {
+			return err
+		}
+		vs.Add(name, s)
+	}
+
+	return nil
+}
+
+// queryValue returns v encoded as the value of a query parameter, encoding a
+// bool as 1 or 0 if asInt is true.
+func queryValue(v reflect.Value, asInt bool) (string, error) {
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.UTC().Format(time.RFC3339), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		if asInt {
+			if v.Bool() {
+				return "1", nil
+			}
+			return "0", nil
+		}
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	}
+	return "", ErrInvalidQuery
+}
+
+// isZero returns true if v holds the zero value of its type.
+func isZero(v reflect.Value) bool {
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.IsZero()
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
@@ -0,0 +1,85 @@
+package els
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Route Test Suite", func() {
+
+	Describe("RouteBuilder", func() {
+		It("escapes each variable as a path segment", func() {
+			r, err := NewRoute("/users/{email}/accessKeys/{id}").
+				Set("email", "a+b/c@test.com").
+				Set("id", "x y?").
+				Build()
+			Expect(err).To(BeNil())
+			Expect(r).To(Equal("/users/a%2Bb%2Fc@test.com/accessKeys/x%20y%3F"))
+		})
+
+		It("builds the query from a struct", func() {
+			type query struct {
+				Expires bool      `url:"expires,int"`
+				Days    uint      `url:"days,omitempty"`
+				Tags    []string  `url:"tag"`
+				Since   time.Time `url:"since,omitempty"`
+				Limit   *int      `url:"limit"`
+				Skip    string    `url:"-"`
+				Name    string
+				hidden  string
+			}
+			now, _ := time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+			r, err := NewRoute("/vendors/{id}/licences").
+				Set("id", "v1").
+				Query(&query{Expires: true, Tags: []string{"a", "b"}, Since: now, Skip: "x", Name: "n&m", hidden: "h"}).
+				Build()
+			Expect(err).To(BeNil())
+			Expect(r).To(Equal("/vendors/v1/licences?Name=n%26m&expires=1&since=2015-01-01T00%3A00%3A00Z&tag=a&tag=b"))
+		})
+
+		It("builds the query from url.Values and maps", func() {
+			r, err := NewRoute("/vendors").Query(url.Values{"a": {"1"}}).Query(map[string]string{"b": "2"}).Build()
+			Expect(err).To(BeNil())
+			Expect(r).To(Equal("/vendors?a=1&b=2"))
+		})
+
+		It("returns an error for a bad template, variable or query", func() {
+			_, err := NewRoute("users/{email}").Set("email", "e").Build()
+			Expect(err).To(Equal(ErrInvalidRouteTemplate))
+			_, err = NewRoute("/users/x{email}").Set("email", "e").Build()
+			Expect(err).To(Equal(ErrInvalidRouteTemplate))
+			_, err = NewRoute("/users/{email}").Build()
+			Expect(err).To(Equal(ErrMissingRouteVar))
+			_, err = NewRoute("/users").Query(3).Build()
+			Expect(err).To(Equal(ErrInvalidQuery))
+			_, err = NewRoute("/users").Query(struct{ C chan int }{}).Build()
+			Expect(err).To(Equal(ErrInvalidQuery))
+		})
+
+		It("makes requests which carry the template", func() {
+			r, err := NewRoute("/users/{email}").Set("email", "a@b.com").NewRequest("GET", nil)
+			Expect(err).To(BeNil())
+			Expect(r.URL.Path).To(Equal("/users/a@b.com"))
+			Expect(RouteFromContext(r.Context())).To(Equal("/users/{email}"))
+
+			_, err = NewRoute("/users/{email}").NewRequest("GET", nil)
+			Expect(err).To(Equal(ErrMissingRouteVar))
+		})
+	})
+
+	Describe("APIHandler.CompleteURL", func() {
+		It("preserves the escaping of the route", func() {
+			r, err := NewRoute("/users/{email}").Set("email", "a/b@test.com").Build()
+			Expect(err).To(BeNil())
+			req, err := http.NewRequest("GET", r, nil)
+			Expect(err).To(BeNil())
+
+			NewAPIHandler(nil).CompleteURL(req.URL)
+			Expect(req.URL.String()).To(Equal("https://api.elasticlicensing.com/1.0/users/a%2Fb@test.com"))
+		})
+	})
+})
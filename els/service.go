@@ -0,0 +1,87 @@
+package els
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrUnknownService is returned by DoWith and GetWith if the service given by
+// WithService has not been added to the caller.
+var ErrUnknownService = errors.New("Unknown Service")
+
+// Service defines a third-party API which is called through an EDAPICaller.
+// Add it with AddService, then call it with DoWith or GetWith, passing the
+// WithService option and a URL relative to BaseURL.
+type Service struct {
+	// Name identifies the service.
+	Name string
+
+	// BaseURL is prefixed to the URLs of calls to the service.
+	BaseURL string
+
+	// Timeout limits calls to the service. If 0, the caller's default
+	// timeout applies.
+	Timeout time.Duration
+
+	// Auth authenticates calls to the service, e.g. with a BearerAuth,
+	// BasicAuth, APIKeyAuth or OAuth2ClientCredentials, or is nil if they
+	// are not authenticated.
+	Auth Signer
+
+	// Header holds headers to set on all calls to the service.
+	Header http.Header
+}
+
+// AddService adds the definition of a third-party API, replacing any with the
+// same name.
+func (a *EDAPICaller) AddService(s Service) {
+	a.Lock()
+	defer a.Unlock()
+	if a.services == nil {
+		a.services = map[string]Service{}
+	}
+	a.services[s.Name] = s
+}
+
+// getService returns the service with the given name, if it has been added.
+func (a *EDAPICaller) getService(name string) (Service, bool) {
+	a.RLock()
+	defer a.RUnlock()
+	s, ok := a.services[name]
+	return s, ok
+}
+
+// WithService makes a call to the service with the given name, added with
+// EDAPICaller.AddService. The settings of the service apply unless overridden
+// by other options.
+func WithService(name string) CallOption {
+	return func(o *CallOptions) {
+		o.Service = name
+	}
+}
+
+// applyService applies the settings of service s to o, except those which are
+// already set.
+func (o *CallOptions) applyService(s Service) {
+	o.IsELSAPI = false
+	if o.BaseURL == "" {
+		o.BaseURL = s.BaseURL
+	}
+	if o.Timeout == 0 {
+		o.Timeout = s.Timeout
+	}
+	if o.Signer == nil {
+		o.Signer = s.Auth
+	}
+	if len(s.Header) > 0 {
+		h := http.Header{}
+		for k, vs := range s.Header {
+			h[http.CanonicalHeaderKey(k)] = append([]string(nil), vs...)
+		}
+		for k, vs := range o.Header {
+			h[k] = vs
+		}
+		o.Header = h
+	}
+}
@@ -0,0 +1,64 @@
+package els
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Service Test Suite", func() {
+
+	var (
+		server *httptest.Server
+		sut    *EDAPICaller
+		rec    *http.Request
+	)
+
+	BeforeEach(func() {
+		rec = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec = r
+		}))
+		sut = NewEDAPICaller(&http.Client{}, datetime.NewNowTimeProvider(), time.Second, "")
+		sut.AddService(Service{
+			Name:    "billing",
+			BaseURL: server.URL + "/v2",
+			Auth:    &BearerAuth{Token: "t"},
+			Header:  http.Header{"Accept": {"application/json"}},
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("calls the service with its settings", func() {
+		rep, err := sut.GetWith(nil, "/invoices", WithService("billing"))
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(rec.URL.Path).To(Equal("/v2/invoices"))
+		Expect(rec.Header.Get("Authorization")).To(Equal("Bearer t"))
+		Expect(rec.Header.Get("Accept")).To(Equal("application/json"))
+	})
+
+	It("lets other options override the settings", func() {
+		rep, err := sut.GetWith(nil, "/invoices", WithService("billing"),
+			WithSigner(&APIKeyAuth{Key: "k"}),
+			WithHeaders(http.Header{"Accept": {"text/csv"}}))
+		Expect(err).To(BeNil())
+		rep.Body.Close()
+		Expect(rec.Header.Get("Authorization")).To(Equal(""))
+		Expect(rec.Header.Get(DefaultAPIKeyHeader)).To(Equal("k"))
+		Expect(rec.Header.Get("Accept")).To(Equal("text/csv"))
+	})
+
+	It("returns ErrUnknownService for a service which has not been added", func() {
+		_, err := sut.GetWith(nil, "/invoices", WithService("unknown"))
+		Expect(err).To(Equal(ErrUnknownService))
+	})
+})
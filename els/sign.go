@@ -1,15 +1,15 @@
 package els
 
 import (
-	"bytes"
 	"crypto/hmac"
-	"crypto/md5"
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/hex"
 	"errors"
-	"io/ioutil"
+	"mime"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,10 +17,26 @@ import (
 )
 
 const (
-	DefaultAPIScheme    = "https"
-	DefaultAPIDomain    = "api.elasticlicensing.com"
-	DefaultAPIVersion   = "1.0"
+	DefaultAPIScheme  = "https"
+	DefaultAPIDomain  = "api.elasticlicensing.com"
+	DefaultAPIVersion = "1.0"
+
+	// RequiredContentType is the content type set on signed requests which
+	// have no content type or a JSON one. Requests with another content type
+	// (e.g. multipart uploads) are signed with their own.
 	RequiredContentType = "application/json;charset=utf-8"
+
+	// MaxPresignTTL is the longest period for which a presigned URL may be
+	// valid.
+	MaxPresignTTL = 7 * 24 * time.Hour
+)
+
+// The query parameters added to a URL by APISigner.Presign.
+const (
+	QueryAccessKeyID = "X-Els-AccessKeyId"
+	QueryDate        = "X-Els-Date"
+	QueryExpires     = "X-Els-Expires"
+	QuerySignature   = "X-Els-Signature"
 )
 
 var (
@@ -29,6 +45,7 @@ var (
 	ErrInvalidAccessKey  = errors.New("Invalid Access Key")
 	ErrExpiredAccessKey  = errors.New("Expired Access Key")
 	ErrRequestInvalidURL = errors.New("Invalid URL")
+	ErrInvalidTTL        = errors.New("Invalid TTL")
 )
 
 // Signer defines the methods that must be implemented by a class that
@@ -37,6 +54,14 @@ type Signer interface {
 	Sign(r *http.Request, now time.Time) error
 }
 
+// VersionSigner is implemented by signers which can sign requests to a given
+// API version as well as those they sign by default, such as APISigner.
+// EDAPICaller uses it to sign requests to its APIHandler.Version.
+type VersionSigner interface {
+	Signer
+	SignVersion(r *http.Request, now time.Time, version string) error
+}
+
 // APISigner implements the Signer interface and is used to modify an
 // http.Request to be 'ELS-signed' by an Access Key (which is bound to an ELS
 // user). ELS API calls must be ELS-signed or they will be immediately
@@ -45,8 +70,20 @@ type Signer interface {
 // request is not authorised to make the request.
 type APISigner struct {
 	accessKey *AccessKey
+
+	// scheme is the signing scheme used to sign requests.
+	scheme SigningScheme
+
+	// signedHeaders lists the (lower-case) headers signed by SchemeV2.
+	signedHeaders []string
+
+	// versions lists the API versions which may be signed, or is empty if
+	// only DefaultAPIVersion may be.
+	versions []string
 }
 
+// NewAPISigner returns an APISigner which signs requests with access key k
+// using SchemeV1.
 func NewAPISigner(k *AccessKey) (a *APISigner, err error) {
 	if k == nil {
 		return nil, ErrNoAccessKey
@@ -63,17 +100,44 @@ func NewAPISigner(k *AccessKey) (a *APISigner, err error) {
 	return a, nil
 }
 
+// AccessKeyID returns the ID of the access key used to sign requests.
+func (s *APISigner) AccessKeyID() AccessKeyID {
+	return s.accessKey.ID
+}
+
+// ExpiryDate returns the expiry date of the access key used to sign requests,
+// or the zero time if it never expires.
+func (s *APISigner) ExpiryDate() time.Time {
+	return s.accessKey.ExpiryDate
+}
+
+// SetAPIVersions sets the API versions (e.g. "1.0", "2.0") to which the signer
+// signs requests. By default, only requests to DefaultAPIVersion are signed.
+func (s *APISigner) SetAPIVersions(vs ...string) {
+	s.versions = vs
+}
+
+// APIVersions returns the API versions set with SetAPIVersions, or nil if
+// none are set and only DefaultAPIVersion is signed.
+func (s *APISigner) APIVersions() []string {
+	return s.versions
+}
+
 // Sign signs the given request using the given access key. It is assumed that
 // the request being signed will be sent immediately.
 func (s *APISigner) Sign(r *http.Request, now time.Time) error {
+	return s.SignVersion(r, now, "")
+}
+
+// SignVersion signs the request as Sign does, but also accepts requests to the
+// given API version if none are set with SetAPIVersions.
+func (s *APISigner) SignVersion(r *http.Request, now time.Time, version string) error {
 
 	if r == nil {
 		return ErrNoRequest
 	}
 
-	// We expect path to begin with the version of the API, which currently
-	// must be 1.0
-	if !strings.HasPrefix(r.URL.Path, "/1.0/") {
+	if !validPath(r.URL.Path, signableVersions(s.versions, version)) {
 		return ErrRequestInvalidURL
 	}
 
@@ -85,30 +149,191 @@ func (s *APISigner) Sign(r *http.Request, now time.Time) error {
 
 	utcStr := now.UTC().Format(time.RFC3339)
 
-	ss := []string{r.Method, "\n"}
+	return signWith(r, utcStr, s.scheme, s.signedHeaders, k.ID, func(fingerprint string) (string, error) {
+		return signature(k.SecretAccessKey, fingerprint), nil
+	})
+}
+
+// signFunc returns the signature of fingerprint, the string to sign.
+type signFunc func(fingerprint string) (string, error)
+
+// signWith signs request r with scheme at the time represented by utcStr, as
+// access key id, using sig to produce the signature.
+func signWith(r *http.Request, utcStr string, scheme SigningScheme, signedHeaders []string, id AccessKeyID, sig signFunc) error {
+
+	if scheme == SchemeV2 {
+		return signV2(r, utcStr, signedHeaders, id, sig)
+	}
+
+	setContentType(r)
+
+	fingerprint, err := requestFingerprint(r, utcStr)
+	if err != nil {
+		return err
+	}
+
+	sg, err := sig(fingerprint)
+	if err != nil {
+		return err
+	}
+
+	auth := strings.Join([]string{"ELS ", string(id), ":", sg}, "")
+
+	r.Header.Set("Authorization", auth)
+	r.Header.Set("X-Els-Date", utcStr)
+
+	log.WithFields(log.Fields{"Time": time.Now(), "fp": fingerprint, "auth": auth, "utcStr": utcStr}).Debug("Signer: sign")
+
+	return nil
+}
+
+// StringToSign returns the canonical string (or 'fingerprint') which Sign would
+// sign for request r at time now, without signing the request. It is useful
+// when diagnosing signature mismatches: see Diagnose.
+func (s *APISigner) StringToSign(r *http.Request, now time.Time) (string, error) {
+
+	if r == nil {
+		return "", ErrNoRequest
+	}
+
+	if !validPath(r.URL.Path, s.versions) {
+		return "", ErrRequestInvalidURL
+	}
+
+	utcStr := now.UTC().Format(time.RFC3339)
+
+	if s.scheme != SchemeV2 {
+		return requestFingerprint(r, utcStr)
+	}
+
+	// The headers which Sign would set are signed by SchemeV2, so set them on a
+	// copy of the request.
+	c := r.WithContext(r.Context())
+	c.Header = r.Header.Clone()
+	c.Header.Set("X-Els-Date", utcStr)
+	setContentType(c)
 
-	hasBody := false // Body might be empty but not nil
-	if r.Body != nil {
-		b, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			return err
+	fingerprint, _, err := requestFingerprintV2(c, utcStr, requestSignedHeaders(c, s.signedHeaders))
+
+	// Digesting the body may have replaced it.
+	r.Body, r.GetBody = c.Body, c.GetBody
+
+	return fingerprint, err
+}
+
+// Presign returns a copy of the URL of request r with query parameters added
+// which carry an ELS signature valid for the period ttl from now. The URL can
+// then be used by clients which cannot set headers, such as a browser following
+// a download link. The request itself is not modified and its body, if any, is
+// not covered by the signature.
+func (s *APISigner) Presign(r *http.Request, now time.Time, ttl time.Duration) (*url.URL, error) {
+
+	if r == nil {
+		return nil, ErrNoRequest
+	}
+
+	if !validPath(r.URL.Path, s.versions) {
+		return nil, ErrRequestInvalidURL
+	}
+
+	if ttl < time.Second || ttl > MaxPresignTTL {
+		return nil, ErrInvalidTTL
+	}
+
+	k := s.accessKey
+
+	if !k.ValidUntil(now, ttl) {
+		return nil, ErrExpiredAccessKey
+	}
+
+	utcStr := now.UTC().Format(time.RFC3339)
+
+	u := *r.URL
+	q := u.Query()
+	q.Del(QuerySignature)
+	q.Set(QueryAccessKeyID, string(k.ID))
+	q.Set(QueryDate, utcStr)
+	q.Set(QueryExpires, strconv.FormatInt(int64(ttl/time.Second), 10))
+
+	fingerprint := presignFingerprint(r.Method, u.Path, utcStr, q)
+	q.Set(QuerySignature, signature(k.SecretAccessKey, fingerprint))
+	u.RawQuery = q.Encode()
+
+	log.WithFields(log.Fields{"Time": time.Now(), "fp": fingerprint, "url": u.String()}).Debug("Signer: presign")
+
+	return &u, nil
+}
+
+// signableVersions returns the API versions which may be signed by a signer
+// set to sign versions, for a caller of the given version: the versions set,
+// or if there are none, DefaultAPIVersion and the caller's version.
+func signableVersions(versions []string, version string) []string {
+	if len(versions) > 0 || version == "" {
+		return versions
+	}
+	return []string{DefaultAPIVersion, version}
+}
+
+// validPath returns true if the path begins with a version of the API (e.g.
+// "/1.0/") which is one of versions, or DefaultAPIVersion if versions is
+// empty.
+func validPath(p string, versions []string) bool {
+	v := PathAPIVersion(p)
+	if v == "" {
+		return false
+	}
+	if len(versions) == 0 {
+		return v == DefaultAPIVersion
+	}
+	for _, allowed := range versions {
+		if v == allowed {
+			return true
 		}
+	}
+	return false
+}
 
-		if len(b) > 0 {
-			hasBody = true
-
-			d := md5.Sum(b)
-			md5s := hex.EncodeToString(d[:])
-			ss = append(ss, md5s, "\n")
-			ss = append(ss, RequiredContentType, "\n")
-			// As we've read the body, we have to put things back so that it can
-			// be read again when the request is eventually sent over the wire...
-			// See https://medium.com/@xoen/golang-read-from-an-io-readwriter-without-loosing-its-content-2c6911805361#.pd96yml71
-			r.Body = ioutil.NopCloser(bytes.NewBuffer(b))
+// presignFingerprint returns the string which is signed to produce the
+// signature of a presigned URL. Unlike requestFingerprint, it covers the query
+// string (less the signature itself) so that the expiry cannot be altered.
+func presignFingerprint(method string, path string, utcStr string, q url.Values) string {
+	return strings.Join([]string{method, "\n\n\n", utcStr, "\n", path, "\n", canonicalQuery(q)}, "")
+}
+
+// canonicalQuery returns q encoded with its keys and the values of each key
+// sorted, omitting any signature parameter.
+func canonicalQuery(q url.Values) string {
+	c := url.Values{}
+	for k, vs := range q {
+		if k == QuerySignature {
+			continue
 		}
+		sorted := append([]string(nil), vs...)
+		sort.Strings(sorted)
+		c[k] = sorted
 	}
+	return c.Encode()
+}
 
-	if !hasBody {
+// requestFingerprint returns the string which is signed to produce the
+// signature of request r at the time represented by utcStr. The body is
+// digested without being consumed (see digestBody).
+func requestFingerprint(r *http.Request, utcStr string) (string, error) {
+
+	ss := []string{r.Method, "\n"}
+
+	d, err := digestBody(r)
+	if err != nil {
+		return "", err
+	}
+
+	if d.Size > 0 { // Body might be empty but not nil
+		if d.MD5 == "" {
+			return "", ErrNoBodyDigest
+		}
+		ss = append(ss, d.MD5, "\n")
+		ss = append(ss, contentType(r), "\n")
+	} else {
 		ss = append(ss, "\n\n")
 	}
 
@@ -116,20 +341,31 @@ func (s *APISigner) Sign(r *http.Request, now time.Time) error {
 
 	ss = append(ss, r.URL.Path)
 
-	fingerprint := strings.Join(ss, "")
-
-	h := hmac.New(sha256.New, []byte(k.SecretAccessKey))
-	h.Write([]byte(fingerprint))
-
-	hStr := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	return strings.Join(ss, ""), nil
+}
 
-	auth := strings.Join([]string{"ELS ", string(k.ID), ":", hStr}, "")
+// setContentType sets the Content-Type of request r to the content type with
+// which it is signed.
+func setContentType(r *http.Request) {
+	r.Header.Set("Content-Type", contentType(r))
+}
 
-	r.Header.Set("Authorization", auth)
-	r.Header.Set("X-Els-Date", utcStr)
-	r.Header.Set("Content-Type", RequiredContentType)
+// contentType returns the content type signed for request r: its own, unless
+// it has none or a JSON one, which is replaced by RequiredContentType as it
+// always has been.
+func contentType(r *http.Request) string {
+	ct := r.Header.Get("Content-Type")
+	if mt, _, err := mime.ParseMediaType(ct); err != nil || mt == "application/json" {
+		return RequiredContentType
+	}
+	return ct
+}
 
-	log.WithFields(log.Fields{"Time": time.Now(), "fp": fingerprint, "auth": auth, "utcStr": utcStr}).Debug("Signer: sign")
+// signature returns the base64-encoded HMAC-SHA256 of fingerprint using the
+// secret access key.
+func signature(secret SecretAccessKey, fingerprint string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(fingerprint))
 
-	return nil
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
 }
@@ -10,6 +10,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -121,6 +122,20 @@ var _ = Describe("Sign Test Suite", func() {
 				})
 			})
 
+			Context("The request has a JSON content type of its own", func() {
+				BeforeEach(func() {
+					json = []byte(`{"title":"ATitle"}`)
+					body = bytes.NewBuffer(json)
+					buildRequest()
+					r.Header.Set("Content-Type", "application/json")
+				})
+				It("replaces it with RequiredContentType, as it always has", func() {
+					Expect(err).To(BeNil())
+					Expect(r.Header.Get("Authorization")).To(Equal(expectedAuth()))
+					Expect(r.Header.Get("Content-Type")).To(Equal(RequiredContentType))
+				})
+			})
+
 			Context("The request has an empty body", func() {
 				BeforeEach(func() {
 					json = make([]byte, 0)
@@ -170,6 +185,34 @@ var _ = Describe("Sign Test Suite", func() {
 				})
 			})
 
+			Context("The path is to another version of the API", func() {
+				BeforeEach(func() {
+					vPrefix = "/2.1"
+					body = nil
+					buildRequest()
+				})
+				It("returns ErrRequestInvalidURL", func() {
+					Expect(err).To(Equal(ErrRequestInvalidURL))
+				})
+				Context("The signer is set to sign that version", func() {
+					BeforeEach(func() {
+						sut.SetAPIVersions("1.0", "2.1")
+					})
+					It("signs the request correctly", func() {
+						Expect(err).To(BeNil())
+						Expect(r.Header.Get("Authorization")).To(Equal(expectedAuth()))
+					})
+				})
+				Context("The signer is set to sign other versions", func() {
+					BeforeEach(func() {
+						sut.SetAPIVersions("1.0", "2.0")
+					})
+					It("returns ErrRequestInvalidURL", func() {
+						Expect(err).To(Equal(ErrRequestInvalidURL))
+					})
+				})
+			})
+
 			Context("The access key has expired", func() {
 				BeforeEach(func() {
 					sut.accessKey.ExpiryDate = now
@@ -179,5 +222,69 @@ var _ = Describe("Sign Test Suite", func() {
 				})
 			})
 		})
+
+		Describe("Presign", func() {
+			var (
+				u   *url.URL
+				ttl time.Duration
+			)
+
+			BeforeEach(func() {
+				ttl = 10 * time.Minute
+			})
+
+			JustBeforeEach(func() {
+				u, err = sut.Presign(r, now, ttl)
+			})
+
+			It("adds the signature to the query string and leaves the request intact", func() {
+				Expect(err).To(BeNil())
+				q := u.Query()
+				Expect(q.Get("X-Els-AccessKeyId")).To(Equal(keyID))
+				Expect(q.Get("X-Els-Date")).To(Equal(utcStr))
+				Expect(q.Get("X-Els-Expires")).To(Equal("600"))
+				Expect(q.Get("X-Els-Signature")).NotTo(BeEmpty())
+				Expect(q).To(HaveKey("query1"))
+				Expect(u.Path).To(Equal(vPrefix + route))
+				Expect(r.URL.RawQuery).To(Equal("query1&query2"))
+				Expect(r.Header.Get("Authorization")).To(BeEmpty())
+			})
+
+			It("produces a signature that depends on the query string", func() {
+				r2, rerr := http.NewRequest(method, vPrefix+route+"?query1&query3", nil)
+				Expect(rerr).To(BeNil())
+				u2, serr := sut.Presign(r2, now, ttl)
+				Expect(serr).To(BeNil())
+				Expect(u2.Query().Get("X-Els-Signature")).NotTo(Equal(u.Query().Get("X-Els-Signature")))
+			})
+
+			Context("The ttl is too long", func() {
+				BeforeEach(func() {
+					ttl = MaxPresignTTL + time.Second
+				})
+				It("returns ErrInvalidTTL", func() {
+					Expect(err).To(Equal(ErrInvalidTTL))
+				})
+			})
+
+			Context("The access key expires before the URL", func() {
+				BeforeEach(func() {
+					ttl = 2 * time.Hour
+				})
+				It("returns ErrExpiredAccessKey", func() {
+					Expect(err).To(Equal(ErrExpiredAccessKey))
+				})
+			})
+
+			Context("The path to sign does not begin with a valid version of the API", func() {
+				BeforeEach(func() {
+					vPrefix = "/invalid"
+					buildRequest()
+				})
+				It("returns ErrRequestInvalidURL", func() {
+					Expect(err).To(Equal(ErrRequestInvalidURL))
+				})
+			})
+		})
 	})
 })
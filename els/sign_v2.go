@@ -0,0 +1,177 @@
+package els
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SigningScheme identifies the version of the ELS signing scheme used by an
+// APISigner.
+type SigningScheme int
+
+const (
+	// SchemeV1 signs the method, the MD5 of the body, the content type, the
+	// date and the path of a request. It is the scheme used by NewAPISigner.
+	SchemeV1 SigningScheme = iota
+
+	// SchemeV2 additionally signs the query string, the host and a
+	// configurable list of headers, and uses SHA-256 to hash the body. It is
+	// the scheme used by NewAPISignerV2.
+	SchemeV2
+)
+
+// Headers added to a request signed with SchemeV2.
+const (
+	HeaderSignedHeaders = "X-Els-Signed-Headers"
+	HeaderContentSHA256 = "X-Els-Content-Sha256"
+)
+
+// The prefix of the string to sign and the Authorization header of a request
+// signed with SchemeV2.
+const (
+	v2Algorithm  = "ELS2-HMAC-SHA256"
+	v2AuthPrefix = "ELS2 "
+)
+
+// ErrInvalidSignedHeaders is returned by NewAPISignerV2 if a header which
+// cannot be signed is requested.
+var ErrInvalidSignedHeaders = errors.New("Invalid Signed Headers")
+
+// requiredSignedHeaders are always signed by SchemeV2.
+var requiredSignedHeaders = []string{"content-type", "host", "x-els-date"}
+
+// NewAPISignerV2 returns an APISigner which signs requests with access key k
+// using SchemeV2. The Host, Content-Type and X-Els-Date headers are always
+// signed; pass the names of any other headers which should be covered by the
+// signature (e.g. "X-Request-Id") in signedHeaders.
+func NewAPISignerV2(k *AccessKey, signedHeaders ...string) (*APISigner, error) {
+	a, err := NewAPISigner(k)
+	if err != nil {
+		return nil, err
+	}
+
+	hs, err := canonicalSignedHeaders(signedHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	a.scheme = SchemeV2
+	a.signedHeaders = hs
+
+	return a, nil
+}
+
+// Scheme returns the signing scheme used by the signer.
+func (s *APISigner) Scheme() SigningScheme {
+	return s.scheme
+}
+
+// signV2 signs request r with SchemeV2 at the time represented by utcStr, as
+// access key id, using sig to produce the signature.
+func signV2(r *http.Request, utcStr string, signedHeaders []string, id AccessKeyID, sig signFunc) error {
+
+	r.Header.Set("X-Els-Date", utcStr)
+	setContentType(r)
+	signedHeaders = requestSignedHeaders(r, signedHeaders)
+
+	fingerprint, bodyHash, err := requestFingerprintV2(r, utcStr, signedHeaders)
+	if err != nil {
+		return err
+	}
+
+	sg, err := sig(fingerprint)
+	if err != nil {
+		return err
+	}
+
+	auth := strings.Join([]string{v2AuthPrefix, string(id), ":", sg}, "")
+
+	r.Header.Set("Authorization", auth)
+	r.Header.Set(HeaderSignedHeaders, strings.Join(signedHeaders, ";"))
+	r.Header.Set(HeaderContentSHA256, bodyHash)
+
+	log.WithFields(log.Fields{"Time": time.Now(), "fp": fingerprint, "auth": auth, "utcStr": utcStr}).Debug("Signer: sign v2")
+
+	return nil
+}
+
+// canonicalSignedHeaders returns the lower-cased, sorted and de-duplicated
+// union of hs and the headers which are always signed.
+func canonicalSignedHeaders(hs []string) ([]string, error) {
+	seen := map[string]bool{}
+	c := []string{}
+
+	all := append(append([]string{}, hs...), requiredSignedHeaders...)
+
+	for _, h := range all {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" || h == "authorization" || strings.ContainsAny(h, ";:") {
+			return nil, ErrInvalidSignedHeaders
+		}
+		if !seen[h] {
+			seen[h] = true
+			c = append(c, h)
+		}
+	}
+
+	sort.Strings(c)
+	return c, nil
+}
+
+// requestFingerprintV2 returns the string which is signed to produce the
+// SchemeV2 signature of request r at the time represented by utcStr, along
+// with the hex-encoded SHA-256 of the body. The body is digested without being
+// consumed (see digestBody).
+func requestFingerprintV2(r *http.Request, utcStr string, signedHeaders []string) (string, string, error) {
+
+	d, err := digestBody(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	if d.SHA256 == "" {
+		return "", "", ErrNoBodyDigest
+	}
+	bodyHash := d.SHA256
+
+	ss := []string{
+		v2Algorithm,
+		utcStr,
+		r.Method,
+		r.URL.EscapedPath(),
+		canonicalQuery(r.URL.Query()),
+	}
+
+	for _, h := range signedHeaders {
+		ss = append(ss, h+":"+canonicalHeaderValue(r, h))
+	}
+
+	ss = append(ss, strings.Join(signedHeaders, ";"), bodyHash)
+
+	return strings.Join(ss, "\n"), bodyHash, nil
+}
+
+// canonicalHeaderValue returns the values of header h in request r, trimmed
+// and separated by commas. The host is taken from the request itself as Go
+// removes it from the headers of incoming requests.
+func canonicalHeaderValue(r *http.Request, h string) string {
+	if h == "host" {
+		if r.Host != "" {
+			return strings.ToLower(r.Host)
+		}
+		return strings.ToLower(r.URL.Host)
+	}
+
+	vs := r.Header[http.CanonicalHeaderKey(h)]
+	t := make([]string, len(vs))
+	for i, v := range vs {
+		t[i] = strings.Join(strings.Fields(v), " ")
+	}
+
+	return strings.Join(t, ",")
+}
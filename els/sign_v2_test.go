@@ -0,0 +1,163 @@
+package els
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sign V2 Test Suite", func() {
+
+	var (
+		keyID    = "AccessKeyID"
+		sac      = "secretAccessKey"
+		now, _   = time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+		utcStr   = now.UTC().Format(time.RFC3339)
+		json     = []byte(`{"title":"ATitle"}`)
+		k        *AccessKey
+		sut      *APISigner
+		verifier *Verifier
+		r        *http.Request
+		extra    []string
+		err      error
+
+		expectedAuth = func() string {
+			ss := "ELS2-HMAC-SHA256\n" + utcStr + "\nPOST\n/1.0/path/to%20route\n"
+			ss += "a=1&a=2&b=3\n"
+			ss += "content-type:application/json;charset=utf-8\n"
+			ss += "host:api.elasticlicensing.com\n"
+			ss += "x-els-date:" + utcStr + "\n"
+			ss += "x-request-id:abc\n"
+			ss += "content-type;host;x-els-date;x-request-id\n"
+			ss += fmt.Sprintf("%x", sha256.Sum256(json))
+			h := hmac.New(sha256.New, []byte(sac))
+			h.Write([]byte(ss))
+			return "ELS2 " + keyID + ":" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+		}
+	)
+
+	BeforeEach(func() {
+		extra = []string{"X-Request-Id"}
+		k = &AccessKey{
+			ID:              AccessKeyID(keyID),
+			SecretAccessKey: SecretAccessKey(sac),
+		}
+		verifier = NewVerifier(KeyStoreFunc(func(id AccessKeyID) (*AccessKey, error) {
+			return k, nil
+		}))
+		r, err = http.NewRequest("POST", "https://api.elasticlicensing.com/1.0/path/to%20route?b=3&a=2&a=1", bytes.NewBuffer(json))
+		Expect(err).To(BeNil())
+		r.Header.Set("X-Request-Id", "abc")
+	})
+
+	Describe("NewAPISignerV2", func() {
+		JustBeforeEach(func() {
+			sut, err = NewAPISignerV2(k, extra...)
+		})
+		It("normalises the signed headers", func() {
+			Expect(err).To(BeNil())
+			Expect(sut.Scheme()).To(Equal(SchemeV2))
+			Expect(sut.signedHeaders).To(Equal([]string{"content-type", "host", "x-els-date", "x-request-id"}))
+		})
+		Context("The Authorization header is to be signed", func() {
+			BeforeEach(func() {
+				extra = []string{"Authorization"}
+			})
+			It("returns ErrInvalidSignedHeaders", func() {
+				Expect(err).To(Equal(ErrInvalidSignedHeaders))
+			})
+		})
+	})
+
+	Describe("Sign", func() {
+		BeforeEach(func() {
+			sut, err = NewAPISignerV2(k, extra...)
+			Expect(err).To(BeNil())
+		})
+
+		JustBeforeEach(func() {
+			err = sut.Sign(r, now)
+		})
+
+		It("signs the request correctly and leaves the body intact", func() {
+			Expect(err).To(BeNil())
+			Expect(r.Header.Get("Authorization")).To(Equal(expectedAuth()))
+			Expect(r.Header.Get("X-Els-Date")).To(Equal(utcStr))
+			Expect(r.Header.Get(HeaderSignedHeaders)).To(Equal("content-type;host;x-els-date;x-request-id"))
+			Expect(r.Header.Get(HeaderContentSHA256)).To(Equal(fmt.Sprintf("%x", sha256.Sum256(json))))
+			b, rerr := ioutil.ReadAll(r.Body)
+			Expect(rerr).To(BeNil())
+			Expect(b).To(Equal(json))
+		})
+
+		Describe("Verification", func() {
+			var vErr error
+
+			JustBeforeEach(func() {
+				_, vErr = verifier.Verify(r, now)
+			})
+
+			It("verifies", func() {
+				Expect(vErr).To(BeNil())
+			})
+
+			Context("The query string is altered after signing", func() {
+				JustBeforeEach(func() {
+					r.URL.RawQuery = "a=1&a=2&b=4"
+					_, vErr = verifier.Verify(r, now)
+				})
+				It("returns ErrSignatureMismatch", func() {
+					Expect(vErr).To(Equal(ErrSignatureMismatch))
+				})
+			})
+
+			Context("A signed header is altered after signing", func() {
+				JustBeforeEach(func() {
+					r.Header.Set("X-Request-Id", "def")
+					_, vErr = verifier.Verify(r, now)
+				})
+				It("returns ErrSignatureMismatch", func() {
+					Expect(vErr).To(Equal(ErrSignatureMismatch))
+				})
+			})
+
+			Context("The host is altered after signing", func() {
+				JustBeforeEach(func() {
+					r.Host = "evil.example.com"
+					_, vErr = verifier.Verify(r, now)
+				})
+				It("returns ErrSignatureMismatch", func() {
+					Expect(vErr).To(Equal(ErrSignatureMismatch))
+				})
+			})
+
+			Context("An unsigned header is altered after signing", func() {
+				JustBeforeEach(func() {
+					r.Header.Set("User-Agent", "another")
+					_, vErr = verifier.Verify(r, now)
+				})
+				It("verifies", func() {
+					Expect(vErr).To(BeNil())
+				})
+			})
+
+			Context("A required header is removed from the signed headers", func() {
+				JustBeforeEach(func() {
+					r.Header.Set(HeaderSignedHeaders, "content-type;x-els-date;x-request-id")
+					_, vErr = verifier.Verify(r, now)
+				})
+				It("returns ErrMalformedSignature", func() {
+					Expect(vErr).To(Equal(ErrMalformedSignature))
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,209 @@
+package els
+
+import (
+	"crypto/hmac"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxClockSkew is the default difference allowed between the time a
+// request was signed and the time it is verified.
+const DefaultMaxClockSkew = 5 * time.Minute
+
+// Errors which may be returned by a Verifier.
+var (
+	ErrNoSignature        = errors.New("No Signature")
+	ErrMalformedSignature = errors.New("Malformed Signature")
+	ErrUnknownAccessKey   = errors.New("Unknown Access Key")
+	ErrSignatureExpired   = errors.New("Signature Expired")
+	ErrSignatureMismatch  = errors.New("Signature Mismatch")
+)
+
+// KeyStore is used by a Verifier to look up the access key which is claimed to
+// have signed a request. It should return ErrUnknownAccessKey (or a nil key)
+// if there is no such key.
+type KeyStore interface {
+	AccessKey(id AccessKeyID) (*AccessKey, error)
+}
+
+// KeyStoreFunc allows an ordinary function to be used as a KeyStore.
+type KeyStoreFunc func(id AccessKeyID) (*AccessKey, error)
+
+// AccessKey implements interface KeyStore.
+func (f KeyStoreFunc) AccessKey(id AccessKeyID) (*AccessKey, error) {
+	return f(id)
+}
+
+// Verifier checks the ELS signatures of incoming requests, whether carried in
+// the headers (see APISigner.Sign) or in the query string of a presigned URL
+// (see APISigner.Presign). It is primarily intended for services which receive
+// webhook callbacks or links generated by the ELS, and for testing.
+type Verifier struct {
+	keys KeyStore
+
+	// MaxClockSkew is the largest difference allowed between the time a
+	// header-signed request was signed and the time it is verified, and the
+	// amount by which the date of a presigned URL may be in the future.
+	MaxClockSkew time.Duration
+}
+
+// NewVerifier returns a Verifier which looks up access keys in ks.
+func NewVerifier(ks KeyStore) *Verifier {
+	return &Verifier{
+		keys:         ks,
+		MaxClockSkew: DefaultMaxClockSkew,
+	}
+}
+
+// Verify checks that request r carries a valid ELS signature at time now and
+// returns the access key which signed it. If the signature is in the headers
+// and the request has a body, the body is digested without being consumed
+// (spooling it to a temporary file if it is large).
+func (v *Verifier) Verify(r *http.Request, now time.Time) (*AccessKey, error) {
+
+	if r == nil {
+		return nil, ErrNoRequest
+	}
+
+	if r.URL.Query().Get(QuerySignature) != "" {
+		return v.verifyPresigned(r, now)
+	}
+
+	if r.Header.Get("Authorization") != "" {
+		return v.verifyHeaders(r, now)
+	}
+
+	return nil, ErrNoSignature
+}
+
+// verifyHeaders verifies a request signed by APISigner.Sign with either
+// signing scheme.
+func (v *Verifier) verifyHeaders(r *http.Request, now time.Time) (*AccessKey, error) {
+
+	scheme, id, sig := parseAuthorization(r.Header.Get("Authorization"))
+	if id == "" {
+		return nil, ErrMalformedSignature
+	}
+
+	utcStr := r.Header.Get("X-Els-Date")
+	t, err := time.Parse(time.RFC3339, utcStr)
+	if err != nil {
+		return nil, ErrMalformedSignature
+	}
+
+	if d := now.Sub(t); d > v.MaxClockSkew || d < -v.MaxClockSkew {
+		return nil, ErrSignatureExpired
+	}
+
+	k, err := v.accessKey(id, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var fingerprint string
+	if scheme == SchemeV2 {
+		fingerprint, err = v2Fingerprint(r, utcStr)
+	} else {
+		fingerprint, err = requestFingerprint(r, utcStr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkSignature(k, fingerprint, sig); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// v2Fingerprint returns the SchemeV2 fingerprint of incoming request r, using
+// the list of signed headers it carries. The list must include the headers
+// which are always signed, or an attacker could strip them.
+func v2Fingerprint(r *http.Request, utcStr string) (string, error) {
+
+	hs := strings.Split(r.Header.Get(HeaderSignedHeaders), ";")
+
+	c, err := canonicalSignedHeaders(hs)
+	if err != nil || len(c) != len(hs) {
+		return "", ErrMalformedSignature
+	}
+
+	for i := range c {
+		if c[i] != hs[i] {
+			return "", ErrMalformedSignature
+		}
+	}
+
+	fingerprint, _, err := requestFingerprintV2(r, utcStr, c)
+	return fingerprint, err
+}
+
+// verifyPresigned verifies a URL generated by APISigner.Presign.
+func (v *Verifier) verifyPresigned(r *http.Request, now time.Time) (*AccessKey, error) {
+
+	q := r.URL.Query()
+
+	utcStr := q.Get(QueryDate)
+	t, err := time.Parse(time.RFC3339, utcStr)
+	if err != nil {
+		return nil, ErrMalformedSignature
+	}
+
+	secs, err := strconv.ParseInt(q.Get(QueryExpires), 10, 64)
+	if err != nil || secs <= 0 {
+		return nil, ErrMalformedSignature
+	}
+
+	if now.Before(t.Add(-v.MaxClockSkew)) || now.After(t.Add(time.Duration(secs)*time.Second)) {
+		return nil, ErrSignatureExpired
+	}
+
+	k, err := v.accessKey(AccessKeyID(q.Get(QueryAccessKeyID)), now)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := presignFingerprint(r.Method, r.URL.Path, utcStr, q)
+
+	if err = checkSignature(k, fingerprint, q.Get(QuerySignature)); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// accessKey looks up the key with the given id and checks it hasn't expired.
+func (v *Verifier) accessKey(id AccessKeyID, now time.Time) (*AccessKey, error) {
+
+	if id == "" {
+		return nil, ErrMalformedSignature
+	}
+
+	k, err := v.keys.AccessKey(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if k == nil || !k.CanSign() {
+		return nil, ErrUnknownAccessKey
+	}
+
+	if !k.ValidUntil(now, 0) {
+		return nil, ErrExpiredAccessKey
+	}
+
+	return k, nil
+}
+
+// checkSignature returns ErrSignatureMismatch if sig is not the signature of
+// fingerprint using access key k.
+func checkSignature(k *AccessKey, fingerprint string, sig string) error {
+	if !hmac.Equal([]byte(signature(k.SecretAccessKey, fingerprint)), []byte(sig)) {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
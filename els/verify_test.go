@@ -0,0 +1,205 @@
+package els
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Verifier Test Suite", func() {
+
+	var (
+		now, _  = time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+		signAt  time.Time
+		verAt   time.Time
+		k       *AccessKey
+		keys    map[AccessKeyID]*AccessKey
+		storeFn = KeyStoreFunc(func(id AccessKeyID) (*AccessKey, error) {
+			return keys[id], nil
+		})
+		signer  *APISigner
+		sut     *Verifier
+		r       *http.Request
+		body    = []byte(`{"title":"ATitle"}`)
+		kResult *AccessKey
+		err     error
+	)
+
+	BeforeEach(func() {
+		signAt = now
+		verAt = now.Add(time.Minute)
+		k = &AccessKey{
+			ID:              AccessKeyID("AccessKeyID"),
+			SecretAccessKey: SecretAccessKey("secretAccessKey"),
+			ExpiryDate:      now.Add(24 * time.Hour),
+		}
+		keys = map[AccessKeyID]*AccessKey{k.ID: k}
+		signer, err = NewAPISigner(k)
+		Expect(err).To(BeNil())
+		sut = NewVerifier(storeFn)
+	})
+
+	Describe("Verify", func() {
+
+		JustBeforeEach(func() {
+			kResult, err = sut.Verify(r, verAt)
+		})
+
+		Context("The request is signed in the headers", func() {
+			BeforeEach(func() {
+				r, err = http.NewRequest("POST", "/1.0/path/to/route?a=b", bytes.NewBuffer(body))
+				Expect(err).To(BeNil())
+				Expect(signer.Sign(r, signAt)).To(BeNil())
+			})
+
+			It("returns the signing key and leaves the body intact", func() {
+				Expect(err).To(BeNil())
+				Expect(kResult).To(Equal(k))
+				b, rerr := ioutil.ReadAll(r.Body)
+				Expect(rerr).To(BeNil())
+				Expect(b).To(Equal(body))
+			})
+
+			Context("The body has been altered", func() {
+				BeforeEach(func() {
+					r.Body = ioutil.NopCloser(bytes.NewBufferString(`{"title":"Altered"}`))
+					r.GetBody = nil
+				})
+				It("returns ErrSignatureMismatch", func() {
+					Expect(err).To(Equal(ErrSignatureMismatch))
+					Expect(kResult).To(BeNil())
+				})
+			})
+
+			Context("The request has its own content type", func() {
+				BeforeEach(func() {
+					r, err = http.NewRequest("PUT", "/1.0/path/to/image", bytes.NewBuffer(body))
+					Expect(err).To(BeNil())
+					r.Header.Set("Content-Type", "image/png")
+					Expect(signer.Sign(r, signAt)).To(BeNil())
+				})
+				It("preserves and verifies it", func() {
+					Expect(err).To(BeNil())
+					Expect(r.Header.Get("Content-Type")).To(Equal("image/png"))
+				})
+
+				Context("The content type has been altered", func() {
+					BeforeEach(func() {
+						r.Header.Set("Content-Type", "image/gif")
+					})
+					It("returns ErrSignatureMismatch", func() {
+						Expect(err).To(Equal(ErrSignatureMismatch))
+					})
+				})
+			})
+
+			Context("The request was signed too long ago", func() {
+				BeforeEach(func() {
+					verAt = signAt.Add(DefaultMaxClockSkew + time.Second)
+				})
+				It("returns ErrSignatureExpired", func() {
+					Expect(err).To(Equal(ErrSignatureExpired))
+				})
+			})
+
+			Context("The access key is unknown", func() {
+				BeforeEach(func() {
+					delete(keys, k.ID)
+				})
+				It("returns ErrUnknownAccessKey", func() {
+					Expect(err).To(Equal(ErrUnknownAccessKey))
+				})
+			})
+
+			Context("The key store fails", func() {
+				storeErr := errors.New("store error")
+				BeforeEach(func() {
+					sut = NewVerifier(KeyStoreFunc(func(id AccessKeyID) (*AccessKey, error) {
+						return nil, storeErr
+					}))
+				})
+				It("returns the key store error", func() {
+					Expect(err).To(Equal(storeErr))
+				})
+			})
+
+			Context("The Authorization header is malformed", func() {
+				BeforeEach(func() {
+					r.Header.Set("Authorization", "ELS nocolon")
+				})
+				It("returns ErrMalformedSignature", func() {
+					Expect(err).To(Equal(ErrMalformedSignature))
+				})
+			})
+		})
+
+		Context("The request has a presigned URL", func() {
+			BeforeEach(func() {
+				r, err = http.NewRequest("GET", "/1.0/path/to/download?file=1", nil)
+				Expect(err).To(BeNil())
+				u, serr := signer.Presign(r, signAt, time.Hour)
+				Expect(serr).To(BeNil())
+				r, err = http.NewRequest("GET", u.String(), nil)
+				Expect(err).To(BeNil())
+			})
+
+			It("returns the signing key", func() {
+				Expect(err).To(BeNil())
+				Expect(kResult).To(Equal(k))
+			})
+
+			Context("The URL has expired", func() {
+				BeforeEach(func() {
+					verAt = signAt.Add(time.Hour + time.Second)
+				})
+				It("returns ErrSignatureExpired", func() {
+					Expect(err).To(Equal(ErrSignatureExpired))
+				})
+			})
+
+			Context("The expiry has been altered", func() {
+				BeforeEach(func() {
+					q := r.URL.Query()
+					q.Set(QueryExpires, "86400")
+					r.URL.RawQuery = q.Encode()
+				})
+				It("returns ErrSignatureMismatch", func() {
+					Expect(err).To(Equal(ErrSignatureMismatch))
+				})
+			})
+
+			Context("A query parameter has been added", func() {
+				BeforeEach(func() {
+					r.URL.RawQuery += "&file=2"
+				})
+				It("returns ErrSignatureMismatch", func() {
+					Expect(err).To(Equal(ErrSignatureMismatch))
+				})
+			})
+
+			Context("The method has been changed", func() {
+				BeforeEach(func() {
+					r.Method = "DELETE"
+				})
+				It("returns ErrSignatureMismatch", func() {
+					Expect(err).To(Equal(ErrSignatureMismatch))
+				})
+			})
+		})
+
+		Context("The request is not signed", func() {
+			BeforeEach(func() {
+				r, err = http.NewRequest("GET", "/1.0/path/to/route", nil)
+				Expect(err).To(BeNil())
+			})
+			It("returns ErrNoSignature", func() {
+				Expect(err).To(Equal(ErrNoSignature))
+			})
+		})
+	})
+})
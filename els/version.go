@@ -0,0 +1,43 @@
+package els
+
+import (
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// PathAPIVersion returns the API version with which path p begins, e.g. "1.0"
+// for "/1.0/users", or "" if p does not begin with a version. A version
+// consists of digits and dots, beginning with a digit.
+func PathAPIVersion(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		return ""
+	}
+	i := strings.Index(p[1:], "/")
+	if i <= 0 {
+		return ""
+	}
+
+	v := p[1 : i+1]
+	if v[0] < '0' || v[0] > '9' {
+		return ""
+	}
+	for _, c := range v {
+		if (c < '0' || c > '9') && c != '.' {
+			return ""
+		}
+	}
+	return v
+}
+
+// UseForVersion adds an interceptor as Use does, but which runs only for ELS
+// API calls to the given API version, so that behaviour which differs between
+// versions (e.g. extra headers, or the handling of responses) can be adapted.
+func (a *EDAPICaller) UseForVersion(version string, stage Stage, i Interceptor) {
+	a.Use(stage, InterceptorFunc(func(ctx context.Context, e *Exchange) error {
+		if !e.IsELSAPI || PathAPIVersion(e.Request.URL.Path) != version {
+			return nil
+		}
+		return i.Intercept(ctx, e)
+	}))
+}
@@ -0,0 +1,111 @@
+package els
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/elasticlic/go-utils/datetime"
+	"golang.org/x/net/context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Version Test Suite", func() {
+
+	Describe("PathAPIVersion", func() {
+		It("returns the version with which a path begins", func() {
+			Expect(PathAPIVersion("/1.0/users")).To(Equal("1.0"))
+			Expect(PathAPIVersion("/2/users")).To(Equal("2"))
+			Expect(PathAPIVersion("/1.0")).To(Equal(""))
+			Expect(PathAPIVersion("/v1/users")).To(Equal(""))
+			Expect(PathAPIVersion("/.1/users")).To(Equal(""))
+			Expect(PathAPIVersion("1.0/users")).To(Equal(""))
+		})
+	})
+
+	Describe("EDAPICaller", func() {
+		var (
+			now, _ = time.Parse(time.RFC3339, "2015-01-01T00:00:00Z")
+			server *httptest.Server
+			sut    *EDAPICaller
+			paths  []string
+		)
+
+		BeforeEach(func() {
+			paths = nil
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				paths = append(paths, r.URL.Path)
+			}))
+			u, err := url.Parse(server.URL)
+			Expect(err).To(BeNil())
+
+			tp := datetime.NewNowTimeProvider()
+			tp.SetNow(now)
+			sut = NewEDAPICaller(&http.Client{}, tp, time.Second, "")
+			sut.APIHandler.Scheme = u.Scheme
+			sut.APIHandler.Domain = u.Host
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		Describe("Signing", func() {
+			var signer *APISigner
+
+			BeforeEach(func() {
+				var err error
+				signer, err = NewAPISigner(&AccessKey{ID: "id", SecretAccessKey: "secret"})
+				Expect(err).To(BeNil())
+				sut.APIHandler.Version = "2.0"
+			})
+
+			It("signs requests to the caller's version", func() {
+				rep, err := sut.Get(nil, "/vendors/v1", signer, true)
+				Expect(err).To(BeNil())
+				rep.Body.Close()
+				Expect(paths).To(Equal([]string{"/2.0/vendors/v1"}))
+			})
+
+			It("doesn't sign requests to other versions", func() {
+				r, err := http.NewRequest("GET", "/3.0/vendors/v1", nil)
+				Expect(err).To(BeNil())
+				Expect(signer.SignVersion(r, now, "2.0")).To(Equal(ErrRequestInvalidURL))
+			})
+
+			Context("The signer is set to sign other versions", func() {
+				BeforeEach(func() {
+					signer.SetAPIVersions("1.0")
+				})
+				It("doesn't sign requests to the caller's version", func() {
+					_, err := sut.Get(nil, "/vendors/v1", signer, true)
+					Expect(err).To(Equal(ErrRequestInvalidURL))
+					Expect(paths).To(BeEmpty())
+				})
+			})
+		})
+
+		Describe("UseForVersion", func() {
+			It("runs the interceptor only for calls to the version", func() {
+				sut.UseForVersion("2.0", StageBeforeSign, InterceptorFunc(func(ctx context.Context, e *Exchange) error {
+					e.Request.URL.Path += "/v2"
+					return nil
+				}))
+
+				rep, err := sut.Get(nil, "/vendors/v1", nil, true)
+				Expect(err).To(BeNil())
+				rep.Body.Close()
+
+				sut.APIHandler.Version = "2.0"
+				rep, err = sut.Get(nil, "/vendors/v1", nil, true)
+				Expect(err).To(BeNil())
+				rep.Body.Close()
+
+				Expect(paths).To(Equal([]string{"/1.0/vendors/v1", "/2.0/vendors/v1/v2"}))
+			})
+		})
+	})
+})